@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mbrt/gmailctl/internal/engine/cfgtest"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+	"github.com/mbrt/gmailctl/internal/reporting"
+)
+
+var (
+	analyzeFilename      string
+	analyzeConflictsOnly bool
+)
+
+// analyzeCmd represents the analyze command
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze the rules of the configuration",
+	Long: `The analyze command contains analyses of the rules in the
+configuration.`,
+}
+
+// analyzeOverlapsCmd represents the analyze overlaps command
+var analyzeOverlapsCmd = &cobra.Command{
+	Use:   "overlaps",
+	Short: "Find rules that can match the same messages",
+	Long: `The overlaps command finds all the pairs of rules that can match
+the same message, and whether one rule implies the other (i.e. all
+the messages it matches are matched by the other). An example of a
+message matched by both rules is printed for every pair.
+
+Pairs of rules with actions that cannot be applied together (e.g.
+different categories) are reported as conflicting. Use
+--conflicts-only to see only those.
+
+The analysis treats the search terms symbolically and has the same
+limitations as the config tests: rules using escaped expressions or
+unsupported query operators are skipped. Overlaps and implications
+are reported only when they can be proven: terms that depend on each
+other (e.g. 'from:a@b.com' and 'from:b.com') are not related.
+
+By default analyze uses the configuration file inside the config
+directory [config.jsonnet].`,
+	Run: func(*cobra.Command, []string) {
+		f := analyzeFilename
+		if f == "" {
+			f = configFilenameFromDir(cfgDir)
+		}
+		if err := analyzeOverlaps(f); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeCmd)
+	analyzeCmd.AddCommand(analyzeOverlapsCmd)
+
+	// Flags and configuration settings
+	analyzeCmd.PersistentFlags().StringVarP(&analyzeFilename, "filename", "f", "", "configuration file")
+	analyzeOverlapsCmd.Flags().BoolVar(&analyzeConflictsOnly, "conflicts-only", false, "show only rules with conflicting actions")
+}
+
+func analyzeOverlaps(path string) error {
+	parseRes, err := parseConfig(path, "", false)
+	if err != nil {
+		return err
+	}
+	rules := parseRes.Res.Rules
+
+	overlaps, err := cfgtest.AnalyzeOverlaps(rules)
+	if err != nil {
+		stderrPrintf("WARNING: %d rules or pairs of rules are excluded from the analysis:\n",
+			len(errors.Errors(err)))
+		stderrPrintf("%+v\n\n", err)
+	}
+
+	numShown, numConflicts := 0, 0
+	for _, o := range overlaps {
+		if o.Conflict != nil {
+			numConflicts++
+		} else if analyzeConflictsOnly {
+			continue
+		}
+		numShown++
+		printOverlap(rules, o)
+	}
+
+	if analyzeConflictsOnly {
+		fmt.Printf("%d pairs of rules with conflicting actions found.\n", numConflicts)
+	} else {
+		fmt.Printf("%d overlapping pairs of rules found (%d with conflicting actions).\n",
+			numShown, numConflicts)
+	}
+	return nil
+}
+
+func printOverlap(rules []parser.Rule, o cfgtest.Overlap) {
+	first := parser.RuleName(o.First, rules[o.First].Source)
+	second := parser.RuleName(o.Second, rules[o.Second].Source)
+
+	fmt.Printf("%s and %s can match the same messages\n", first, second)
+	switch {
+	case o.FirstImpliesSecond && o.SecondImpliesFirst:
+		fmt.Println("  the rules match exactly the same messages")
+	case o.FirstImpliesSecond:
+		fmt.Printf("  all the messages matched by rule #%d are matched by rule #%d\n", o.First, o.Second)
+	case o.SecondImpliesFirst:
+		fmt.Printf("  all the messages matched by rule #%d are matched by rule #%d\n", o.Second, o.First)
+	}
+	if o.Conflict != nil {
+		fmt.Printf("  conflicting actions: %v\n", o.Conflict)
+	}
+	fmt.Printf("  example: %s\n\n", reporting.Prettify(o.Witness, true))
+}
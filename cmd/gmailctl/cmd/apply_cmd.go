@@ -6,25 +6,79 @@ import (
 	"github.com/spf13/cobra"
 
 	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/backup"
 	"github.com/mbrt/gmailctl/internal/errors"
 )
 
 var (
-	applyFilename     string
-	applyYes          bool
-	applyRemoveLabels bool
-	applySkipTests    bool
-	applyDebug        bool
-	applyDiffContext  int
+	applyFilename      string
+	applyYes           bool
+	applyRemoveLabels  bool
+	applySkipTests     bool
+	applyDebug         bool
+	applyDiffContext   int
+	applyMaxChanges    int
+	applyMaxDeletions  int
+	applyForceMassDel  bool
+	applyForceNonempty bool
+	applyInteractive   bool
+	applyOnlyLabels    []string
+	applyTags          []string
 )
 
 const renameLabelWarning = `Warning: You are going to delete labels. This operation is
 irreversible, because it also removes those labels from messages.
 
-If you are looking for renaming labels, please use the GMail UI.
+If you are looking for renaming labels, list their old names in
+'previousNames' in the config.
 
 `
 
+// warnLabelRemovals warns about the labels removed by the diff, listing how
+// many messages have each of them.
+func warnLabelRemovals(diff papply.ConfigDiff, gmailapi papply.UsageAPI) ([]papply.RemovedLabel, error) {
+	removed, err := papply.RemovedLabels(diff, gmailapi)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print(renameLabelWarning)
+	fmt.Println("Labels to delete:")
+	for _, r := range removed {
+		fmt.Printf("  %s: %s\n", r.Label.Name, r.Usage)
+	}
+	fmt.Println()
+	return removed, nil
+}
+
+// checkLabelRemovals warns about the labels removed by the diff, and refuses
+// to remove the ones that still have messages, unless forced.
+func checkLabelRemovals(diff papply.ConfigDiff, gmailapi papply.UsageAPI, force bool) error {
+	if len(diff.LabelsDiff.Removed) == 0 {
+		return nil
+	}
+	removed, err := warnLabelRemovals(diff, gmailapi)
+	if err != nil {
+		return err
+	}
+	return checkNonEmpty(removed, force)
+}
+
+// checkNonEmpty returns an error if some of the removed labels still have
+// messages, unless forced.
+func checkNonEmpty(removed []papply.RemovedLabel, force bool) error {
+	if err := papply.CheckEmpty(removed); err != nil && !force {
+		return errors.WithDetails(fmt.Errorf("no changes have been made: %w", err),
+			"Labels with messages are only deleted if you also\n"+
+				"provide the --force-nonempty flag.\n")
+	}
+	return nil
+}
+
+const limitExceededDetails = `To protect you from mistakes in the config, the number of changes
+is limited by the --max-changes and --max-deletions flags, or by the
+'apply' settings in the config. Deleting most of your filters at once
+also requires the --force-mass-deletion flag.`
+
 // applyCmd represents the apply command
 var applyCmd = &cobra.Command{
 	Use:   "apply",
@@ -32,6 +86,27 @@ var applyCmd = &cobra.Command{
 	Long: `The apply command applies minimal changes to your Gmail settings
 to make them match your local configuration file.
 
+Changes can be limited with --max-changes and --max-deletions, or
+with the 'maxChanges' and 'maxDeletions' fields of the 'apply'
+settings in the config, in which case apply refuses to make any
+change beyond them. Regardless of the limits, apply refuses to
+delete most of the filters in Gmail, unless --force-mass-deletion
+is given.
+
+With --interactive, apply walks through the changes one by one,
+asking whether to apply each of them, and applies only the selected
+ones.
+
+With --only-label and --tag, apply is restricted to the filters
+applying the given labels, or generated by the rules with the given
+tags, together with their labels. All the other filters and labels
+in Gmail are left untouched. Label patterns can use '*' to match
+a part of the name and '**' for any nested label (e.g. 'Work/**').
+
+Labels are only deleted with --remove-labels, and labels that still
+have messages also require --force-nonempty. The number of messages
+of each label to delete is shown before applying.
+
 By default apply uses the configuration file inside the config
 directory [config.jsonnet].`,
 	Run: func(*cobra.Command, []string) {
@@ -54,12 +129,22 @@ func init() {
 	applyCmd.Flags().BoolVar(&applySkipTests, "yolo", false, "skip configuration tests")
 	applyCmd.PersistentFlags().BoolVar(&applyDebug, "debug", false, "print extra debugging information")
 	applyCmd.PersistentFlags().IntVar(&applyDiffContext, "diff-context", papply.DefaultContextLines, "number of lines of filter diff context to show")
+	applyCmd.Flags().IntVar(&applyMaxChanges, "max-changes", -1, "refuse to apply more than this number of changes (-1 to use the config)")
+	applyCmd.Flags().IntVar(&applyMaxDeletions, "max-deletions", -1, "refuse to delete more than this number of filters and labels (-1 to use the config)")
+	applyCmd.Flags().BoolVar(&applyForceMassDel, "force-mass-deletion", false, "allow deleting most of the filters in Gmail")
+	applyCmd.Flags().BoolVar(&applyForceNonempty, "force-nonempty", false, "allow removing labels that still have messages")
+	applyCmd.Flags().BoolVar(&applyInteractive, "interactive", false, "select the changes to apply one by one")
+	applyCmd.Flags().StringArrayVar(&applyOnlyLabels, "only-label", nil, "only apply the changes to these labels and the filters applying them")
+	applyCmd.Flags().StringArrayVar(&applyTags, "tag", nil, "only apply the changes to the rules with these tags")
 }
 
-func apply(path string, interactive, test bool) error {
+func apply(path string, askConfirm, test bool) error {
 	if applyDiffContext < 0 {
 		return errors.New("--diff-context must be non-negative")
 	}
+	if applyInteractive && applyYes {
+		return errors.New("--interactive and --yes cannot be used together")
+	}
 
 	useColor := shouldUseColorDiff()
 
@@ -83,32 +168,119 @@ func apply(path string, interactive, test bool) error {
 		return fmt.Errorf("cannot compare upstream with local config: %w", err)
 	}
 
+	if len(applyOnlyLabels) > 0 || len(applyTags) > 0 {
+		scope, err := papply.NewScope(applyOnlyLabels, applyTags, parseRes.Res.Rules)
+		if err != nil {
+			return err
+		}
+		diff, err = diff.Restrict(scope)
+		if err != nil {
+			return fmt.Errorf("restricting the changes: %w", err)
+		}
+	}
+
 	if diff.Empty() {
 		fmt.Println("No changes have been made.")
 		return nil
 	}
 
-	fmt.Printf("You are going to apply the following changes to your settings:\n\n%s\n", diff)
+	if applyInteractive {
+		diff, err = selectChanges(diff)
+		if err != nil {
+			return err
+		}
+		if diff.Empty() {
+			fmt.Println("No changes have been selected.")
+			return nil
+		}
+		fmt.Printf("Summary of the selected changes: %s\n\n", diff.Summary())
+	} else {
+		fmt.Printf("You are going to apply the following changes to your settings:\n\n%s\n", diff)
+		fmt.Printf("Summary: %s\n\n", diff.Summary())
+	}
 
 	if err := diff.Validate(); err != nil {
 		return err
 	}
 
 	if len(diff.LabelsDiff.Removed) > 0 {
-		fmt.Print(renameLabelWarning)
+		removed, err := warnLabelRemovals(diff, gmailapi)
+		if err != nil {
+			return err
+		}
 		if !applyRemoveLabels {
 			return errors.WithDetails(errors.New("no changes have been made"),
 				"To protect you, deletion is disabled unless you\n"+
 					"explicitly provide the --remove-labels flag.\n")
 		}
+		if err := checkNonEmpty(removed, applyForceNonempty); err != nil {
+			return err
+		}
+	}
+
+	limits := applyLimits(parseRes.Res.Limits)
+	if err := checkLimits(diff, limits, applyRemoveLabels); err != nil {
+		return err
 	}
 
-	if interactive && !askYN("Do you want to apply them?") {
+	// In interactive mode, each change has already been confirmed.
+	if askConfirm && !applyInteractive && !askYN("Do you want to apply them?") {
 		return nil
 	}
 
 	fmt.Println("Applying the changes...")
-	return papply.Apply(diff, gmailapi, applyRemoveLabels)
+	return applyWithJournal("apply", diff, gmailapi, applyRemoveLabels, limits,
+		backup.RetentionFromConfig(parseRes.Config.Apply))
+}
+
+// selectChanges asks which changes of the diff to apply, one by one, and
+// returns a diff with only the selected ones.
+func selectChanges(diff papply.ConfigDiff) (papply.ConfigDiff, error) {
+	quit := false
+	res, err := diff.Select(func(change papply.ConfigDiff) bool {
+		if quit {
+			return false
+		}
+		fmt.Printf("%s\n", change)
+		switch askOptions("Apply this change?", []string{"yes", "no", "quit (skip all the remaining changes)"}) {
+		case 0:
+			return true
+		case 1:
+			return false
+		default:
+			quit = true
+			return false
+		}
+	})
+	if err != nil {
+		return res, fmt.Errorf("invalid selection: %w", err)
+	}
+	return res, nil
+}
+
+// applyLimits returns the limits set in the config, overridden by the
+// ones passed as flags.
+func applyLimits(cfgLimits papply.Limits) papply.Limits {
+	res := cfgLimits
+	if applyMaxChanges >= 0 {
+		res.MaxChanges = applyMaxChanges
+	}
+	if applyMaxDeletions >= 0 {
+		res.MaxDeletions = applyMaxDeletions
+	}
+	res.AllowMassDeletion = applyForceMassDel
+	return res
+}
+
+// checkLimits returns an error if the diff exceeds the limits, explaining
+// how to change them.
+func checkLimits(diff papply.ConfigDiff, limits papply.Limits, allowRemoveLabels bool) error {
+	err := limits.Check(diff, allowRemoveLabels)
+	if errors.Is(err, papply.ErrLimitExceeded) {
+		return errors.WithDetails(fmt.Errorf("no changes have been made: %w", err),
+			limitExceededDetails)
+	}
+	return err
 }
 
 func configurationError(err error) error {
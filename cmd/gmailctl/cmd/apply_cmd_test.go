@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/journal"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+type usageAPI map[string]label.Usage
+
+func (a usageAPI) LabelUsage(id string) (label.Usage, error) {
+	return a[id], nil
+}
+
+func TestCheckLabelRemovalsEdit(t *testing.T) {
+	upstream := papply.GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "work"}, {ID: "l2", Name: "news"}},
+	}
+	// The edited config drops the 'work' label.
+	local := papply.GmailConfig{Labels: label.Labels{{Name: "news"}}}
+	diff, err := papply.Diff(local, upstream, false, papply.DefaultContextLines, false)
+	require.Nil(t, err)
+
+	api := usageAPI{"l1": {Messages: 3, Threads: 2}}
+	err = checkLabelRemovals(diff, api, false)
+	assert.ErrorContains(t, err, papply.ErrNonEmptyLabels.Error())
+	assert.Nil(t, checkLabelRemovals(diff, api, true))
+	assert.Nil(t, checkLabelRemovals(diff, usageAPI{}, false))
+}
+
+func TestCheckLabelRemovalsUndo(t *testing.T) {
+	// Undoing the creation of a label that got messages in the meantime.
+	e := journal.Entry{
+		Operations: []journal.Operation{
+			{Kind: journal.CreateLabel, ID: "l2", LabelAfter: &label.Label{ID: "l2", Name: "news"}},
+		},
+	}
+	upstream := papply.GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "work"}, {ID: "l2", Name: "news"}},
+	}
+	diff, err := papply.Diff(journal.Revert(e, upstream), upstream, false, papply.DefaultContextLines, false)
+	require.Nil(t, err)
+
+	api := usageAPI{"l2": {Messages: 1, Threads: 1}}
+	err = checkLabelRemovals(diff, api, false)
+	assert.ErrorContains(t, err, papply.ErrNonEmptyLabels.Error())
+	assert.Nil(t, checkLabelRemovals(diff, api, true))
+}
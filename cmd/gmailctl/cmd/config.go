@@ -48,6 +48,9 @@ func parseConfig(path, originalPath string, test bool) (parseResult, error) {
 	if err != nil {
 		return res, err
 	}
+	for _, w := range res.Res.Warnings {
+		stderrPrintf("WARNING: %s.\n", w)
+	}
 	if test && len(res.Config.Tests) > 0 {
 		ts, err := cfgtest.NewFromParserRules(res.Res.Rules)
 		if err != nil {
@@ -63,3 +66,17 @@ func parseConfig(path, originalPath string, test bool) (parseResult, error) {
 
 	return res, err
 }
+
+// applySettings returns the 'apply' settings of the config, for the
+// commands changing Gmail without applying it. A missing config has the
+// default settings.
+func applySettings() (*v1alpha3.ApplySettings, error) {
+	cfg, err := config.ReadFile(configFilenameFromDir(cfgDir), "")
+	if err != nil {
+		if errors.Is(err, config.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("syntax error in config file: %w", err)
+	}
+	return cfg.Apply, nil
+}
@@ -63,6 +63,9 @@ func debug(path string) error {
 			return fmt.Errorf("generating criteria: %w", err)
 		}
 
+		if parsed.Source != "" {
+			fmt.Printf("# Source: %s\n", parsed.Source)
+		}
 		fmt.Printf("# Search: %s\n", criteria.ToGmailSearch())
 		fmt.Printf("# URL: %s\n", criteria.ToGmailSearchURL())
 		cfg := parsedRules[i]
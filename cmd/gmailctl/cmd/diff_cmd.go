@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -13,6 +14,9 @@ var (
 	diffFilename string
 	diffDebug    bool
 	diffContext  int
+	diffFrom     string
+	diffGitRev   string
+	diffFormat   string
 )
 
 // diffCmd represents the diff command
@@ -22,8 +26,30 @@ var diffCmd = &cobra.Command{
 	Long: `The diff command shows the difference between the local
 configuration and the current Gmail settings of your account.
 
+With --from or --git-rev, the configuration is compared with another
+configuration file, or with the same file at a given git revision,
+instead of Gmail. Both configurations are evaluated offline, so no
+credentials are needed. This is useful to review the impact of a
+change on the generated filters.
+
+The diff can be printed as text (the default) or as JSON, with
+--format json. The markdown and html formats render a self-contained
+report, with the filter changes grouped by label, suitable to be
+posted as is by a CI step (e.g. as a pull request comment).
+
 By default diff uses the configuration file inside the config
 directory [config.jsonnet].`,
+	Example: `# Compare with the Gmail settings
+gmailctl diff
+
+# Compare with the previous commit
+gmailctl diff --git-rev HEAD~1
+
+# Compare with another file, in JSON
+gmailctl diff --from config-old.jsonnet --format json
+
+# Report the changes of the last commit in Markdown
+gmailctl diff --git-rev HEAD~1 --format markdown`,
 	Run: func(*cobra.Command, []string) {
 		f := diffFilename
 		if f == "" {
@@ -42,26 +68,32 @@ func init() {
 	diffCmd.PersistentFlags().StringVarP(&diffFilename, "filename", "f", "", "configuration file")
 	diffCmd.PersistentFlags().BoolVar(&diffDebug, "debug", false, "print extra debugging information")
 	diffCmd.PersistentFlags().IntVar(&diffContext, "context", papply.DefaultContextLines, "number of lines of filter diff context to show")
+	diffCmd.PersistentFlags().StringVar(&diffFrom, "from", "", "compare with this configuration file, instead of Gmail")
+	diffCmd.PersistentFlags().StringVar(&diffGitRev, "git-rev", "", "compare with the configuration file at this git revision, instead of Gmail")
+	diffCmd.PersistentFlags().StringVar(&diffFormat, "format", "text", "output format (text, json, markdown, html)")
 }
 
 func diff(path string) error {
 	if diffContext < 0 {
 		return errors.New("--context must be non-negative")
 	}
+	if diffFrom != "" && diffGitRev != "" {
+		return errors.New("--from and --git-rev cannot be used together")
+	}
+	switch diffFormat {
+	case "text", "json", "markdown", "html":
+	default:
+		return fmt.Errorf("unsupported format %q", diffFormat)
+	}
 
-	useColor := shouldUseColorDiff()
+	useColor := diffFormat == "text" && shouldUseColorDiff()
 
 	parseRes, err := parseConfig(path, "", false)
 	if err != nil {
 		return err
 	}
 
-	gmailapi, err := openAPI()
-	if err != nil {
-		return configurationError(fmt.Errorf("cannot connect to Gmail: %w", err))
-	}
-
-	upstream, err := upstreamConfig(gmailapi)
+	upstream, err := diffBaseConfig(path)
 	if err != nil {
 		return err
 	}
@@ -71,6 +103,50 @@ func diff(path string) error {
 		return fmt.Errorf("cannot compare upstream with local config: %w", err)
 	}
 
-	fmt.Print(diff)
+	switch diffFormat {
+	case "json":
+		b, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding the diff: %w", err)
+		}
+		fmt.Println(string(b))
+	case "markdown":
+		fmt.Print(papply.RenderMarkdown(diff))
+	case "html":
+		fmt.Print(papply.RenderHTML(diff))
+	default:
+		fmt.Print(diff)
+	}
 	return nil
 }
+
+// diffBaseConfig returns the configuration to compare the local one with:
+// either another config file, or the Gmail settings.
+func diffBaseConfig(path string) (papply.GmailConfig, error) {
+	switch {
+	case diffFrom != "":
+		res, err := parseConfig(diffFrom, "", false)
+		if err != nil {
+			return papply.GmailConfig{}, fmt.Errorf("parsing %s: %w", diffFrom, err)
+		}
+		return res.Res.GmailConfig, nil
+
+	case diffGitRev != "":
+		revPath, cleanup, err := configAtRevision(path, diffGitRev)
+		if err != nil {
+			return papply.GmailConfig{}, err
+		}
+		defer cleanup()
+		res, err := parseConfig(revPath, "", false)
+		if err != nil {
+			return papply.GmailConfig{}, fmt.Errorf("parsing %s at revision %q: %w", path, diffGitRev, err)
+		}
+		return res.Res.GmailConfig, nil
+	}
+
+	gmailapi, err := openAPI()
+	if err != nil {
+		return papply.GmailConfig{}, configurationError(fmt.Errorf("cannot connect to Gmail: %w", err))
+	}
+	return upstreamConfig(gmailapi)
+}
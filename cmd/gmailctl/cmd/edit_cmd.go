@@ -11,16 +11,19 @@ import (
 
 	"github.com/mbrt/gmailctl/internal/engine/api"
 	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/backup"
 	"github.com/mbrt/gmailctl/internal/engine/config"
 	"github.com/mbrt/gmailctl/internal/errors"
 )
 
 // Parameters
 var (
-	editFilename    string
-	editSkipTests   bool
-	editDebug       bool
-	editDiffContext int
+	editFilename      string
+	editSkipTests     bool
+	editDebug         bool
+	editDiffContext   int
+	editForceMassDel  bool
+	editForceNonempty bool
 )
 
 var editUseColor bool
@@ -53,6 +56,12 @@ make it match your desired state.
 The editor to be used can be overridden with the $EDITOR
 environment variable.
 
+The limits set in the 'apply' settings of the config are enforced,
+and edit refuses to delete most of the filters in Gmail, unless
+--force-mass-deletion is given. Deleting labels requires an explicit
+confirmation, and labels that still have messages are only deleted
+with --force-nonempty.
+
 By default edit uses the configuration file inside the config
 directory [config.jsonnet].`,
 	Run: func(*cobra.Command, []string) {
@@ -74,6 +83,8 @@ func init() {
 	editCmd.Flags().BoolVarP(&editSkipTests, "yolo", "", false, "skip configuration tests")
 	editCmd.PersistentFlags().BoolVar(&editDebug, "debug", false, "print extra debugging information")
 	editCmd.PersistentFlags().IntVar(&editDiffContext, "diff-context", papply.DefaultContextLines, "number of lines of filter diff context to show")
+	editCmd.Flags().BoolVar(&editForceMassDel, "force-mass-deletion", false, "allow deleting most of the filters in Gmail")
+	editCmd.Flags().BoolVar(&editForceNonempty, "force-nonempty", false, "allow removing labels that still have messages")
 }
 
 func edit(path string, test bool) error {
@@ -222,6 +233,14 @@ func spawnEditor(path string) error {
 	return errors.New("no suitable editor found")
 }
 
+// editLimits returns the limits set in the config, with the mass deletion
+// check disabled by --force-mass-deletion.
+func editLimits(cfgLimits papply.Limits) papply.Limits {
+	res := cfgLimits
+	res.AllowMassDeletion = editForceMassDel
+	return res
+}
+
 func applyEdited(path, originalPath string, test bool, gmailapi *api.GmailAPI) error {
 	parseRes, err := parseConfig(path, originalPath, test)
 	if err != nil {
@@ -244,14 +263,22 @@ func applyEdited(path, originalPath string, test bool, gmailapi *api.GmailAPI) e
 	}
 
 	fmt.Printf("You are going to apply the following changes to your settings:\n\n%s\n", diff)
+	fmt.Printf("Summary: %s\n\n", diff.Summary())
 
 	if err := diff.Validate(); err != nil {
 		return err
 	}
 
+	limits := editLimits(parseRes.Res.Limits)
+	if err := checkLimits(diff, limits, true); err != nil {
+		return err
+	}
+
 	yesOption := "yes"
 	if len(diff.LabelsDiff.Removed) > 0 {
-		fmt.Print(renameLabelWarning)
+		if err := checkLabelRemovals(diff, gmailapi, editForceNonempty); err != nil {
+			return err
+		}
 		yesOption = "yes, and I ALSO WANT TO DELETE LABELS"
 	}
 
@@ -265,5 +292,6 @@ func applyEdited(path, originalPath string, test bool, gmailapi *api.GmailAPI) e
 	}
 
 	fmt.Println("Applying the changes...")
-	return papply.Apply(diff, gmailapi, true)
+	return applyWithJournal("edit", diff, gmailapi, true, limits,
+		backup.RetentionFromConfig(parseRes.Config.Apply))
 }
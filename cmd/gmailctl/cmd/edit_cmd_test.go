@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+)
+
+func TestEditLimitsMassDeletion(t *testing.T) {
+	var upstream papply.GmailConfig
+	for i := 0; i < 8; i++ {
+		upstream.Filters = append(upstream.Filters, filter.Filter{
+			ID:       fmt.Sprintf("id%d", i),
+			Criteria: filter.Criteria{From: fmt.Sprintf("user%d@example.com", i)},
+			Action:   filter.Actions{Archive: true},
+		})
+	}
+	// The edited config drops all the filters.
+	diff, err := papply.Diff(papply.GmailConfig{}, upstream, false, papply.DefaultContextLines, false)
+	require.Nil(t, err)
+
+	defer func() { editForceMassDel = false }()
+
+	editForceMassDel = false
+	err = checkLimits(diff, editLimits(papply.LimitsFromConfig(nil)), true)
+	assert.ErrorContains(t, err, papply.ErrLimitExceeded.Error())
+
+	editForceMassDel = true
+	assert.Nil(t, checkLimits(diff, editLimits(papply.LimitsFromConfig(nil)), true))
+
+	// The limits in the config still apply.
+	maxDeletions := 3
+	cfgLimits := papply.LimitsFromConfig(&v1alpha3.ApplySettings{MaxDeletions: &maxDeletions})
+	err = checkLimits(diff, editLimits(cfgLimits), true)
+	assert.ErrorContains(t, err, papply.ErrLimitExceeded.Error())
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mbrt/gmailctl/internal/engine/cfgtest"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+	"github.com/mbrt/gmailctl/internal/errors"
+	"github.com/mbrt/gmailctl/internal/reporting"
+)
+
+// equivCmd represents the equiv command
+var equivCmd = &cobra.Command{
+	Use:   "equiv <old config> <new config>",
+	Short: "Check that two configurations are equivalent",
+	Long: `The equiv command checks that two configuration files produce
+the same results. This is useful when refactoring a configuration,
+to make sure that nothing changed.
+
+The filters generated by the two configurations are compared first.
+If they differ, the rules are analysed to check whether they apply the
+same actions to every message, even if they are structured in a
+different way. When they don't, an example of a message that gets
+different actions is printed.
+
+The analysis has the same limitations as the config tests: rules using
+escaped expressions or unsupported query operators cannot be compared.
+Search terms are compared as they are, so rules whose terms depend on
+each other (e.g. 'from:a@b.com' and 'from:b.com') might be impossible
+to prove equivalent, even if no difference is found.
+
+The command exits with status 0 if the configurations are equivalent,
+1 if they are not (or on errors) and 2 if the equivalence is unknown.`,
+	Example: `gmailctl equiv config-old.jsonnet config.jsonnet`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(_ *cobra.Command, args []string) {
+		err := equiv(args[0], args[1])
+		if errors.Is(err, errEquivUnknown) {
+			stderrPrintf("Error: %v\n", err)
+			os.Exit(equivUnknownStatus)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// equivUnknownStatus is the exit status when the equivalence can't be
+// proven, nor disproven.
+const equivUnknownStatus = 2
+
+var errEquivUnknown = errors.New("cannot prove that the configs are equivalent")
+
+func init() {
+	rootCmd.AddCommand(equivCmd)
+}
+
+func equiv(oldPath, newPath string) error {
+	oldRes, err := parseConfig(oldPath, "", false)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", oldPath, err)
+	}
+	newRes, err := parseConfig(newPath, "", false)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", newPath, err)
+	}
+
+	ldiff, err := label.Diff(oldRes.Res.Labels, newRes.Res.Labels, nil, false)
+	if err != nil {
+		return fmt.Errorf("cannot compare labels: %w", err)
+	}
+	if !ldiff.Empty() {
+		fmt.Printf("Labels are different:\n%s\n", ldiff)
+		return errors.New("the configs are not equivalent: the labels are different")
+	}
+
+	fdiff, err := filter.Diff(oldRes.Res.Filters, newRes.Res.Filters, filter.DiffOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot compare filters: %w", err)
+	}
+	if fdiff.Empty() {
+		fmt.Println("The generated filters are identical.")
+		return nil
+	}
+
+	fmt.Println("The generated filters are different, comparing the rules.")
+	diffs, err := cfgtest.CompareRules(oldRes.Res.Rules, newRes.Res.Rules)
+	for _, d := range diffs {
+		fmt.Printf("\nAction %q is applied differently, for example to message:\n", d.Action)
+		fmt.Printf("  %s\n", reporting.Prettify(d.Message, true))
+		fmt.Printf("  old actions: %s\n", reporting.Prettify(d.Before, true))
+		fmt.Printf("  new actions: %s\n", reporting.Prettify(d.After, true))
+	}
+	if err != nil {
+		stderrPrintf("%+v\n", err)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("the configs are not equivalent: %d actions are applied differently", len(diffs))
+	}
+	if err != nil {
+		fmt.Println("No difference found (not proven).")
+		return errEquivUnknown
+	}
+
+	fmt.Println("The rules are equivalent: every message gets the same actions.")
+	return nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// configAtRevision extracts the directory containing the given config file,
+// as it was at the given git revision, into a temporary directory. Files
+// imported by the config are extracted as well, as long as they are in the
+// same directory or below.
+//
+// The path of the extracted config file is returned, together with a
+// function removing the temporary directory.
+func configAtRevision(path, rev string) (string, func(), error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	dir := filepath.Dir(abs)
+
+	out, err := runGit(dir, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return "", nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	// The prefix is empty when dir is the top level directory.
+	toplevel, prefix, _ := strings.Cut(strings.TrimSpace(out)+"\n", "\n")
+	// The archive has to be created from the top level, otherwise git only
+	// includes the files under the current directory.
+	archive, err := runGit(toplevel, "archive", "--format=tar", rev+":"+strings.TrimSpace(prefix))
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot read revision %q: %w", rev, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "gmailctl-rev-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { _ = os.RemoveAll(tmpDir) }
+	if err := extractTar(strings.NewReader(archive), tmpDir); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("extracting revision %q: %w", rev, err)
+	}
+
+	return filepath.Join(tmpDir, filepath.Base(abs)), cleanup, nil
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	/* #nosec */
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// extractTar extracts directories and regular files of the archive into
+// dir. Other kinds of entries are skipped.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !filepath.IsLocal(hdr.Name) {
+			return fmt.Errorf("invalid path in archive: %q", hdr.Name)
+		}
+		p := filepath.Join(dir, hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(p, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeTarFile(p, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeTarFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	/* #nosec */
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
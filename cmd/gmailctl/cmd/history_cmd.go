@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/backup"
+	"github.com/mbrt/gmailctl/internal/engine/journal"
+)
+
+var historyVerbose bool
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the changes applied to Gmail",
+	Long: `The history command lists the changes applied to Gmail by
+gmailctl, from the oldest. They are recorded in a journal in the
+config directory.
+
+Each apply is numbered, and can be undone with 'gmailctl undo N'.`,
+	Run: func(*cobra.Command, []string) {
+		if err := history(); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	// Flags and configuration settings
+	historyCmd.Flags().BoolVarP(&historyVerbose, "verbose", "v", false, "list every operation")
+}
+
+func history() error {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No changes have been recorded.")
+		return nil
+	}
+
+	for i, e := range entries {
+		fmt.Printf("#%d  %s  %s  %s\n", i+1, e.Time.Local().Format(time.DateTime), e.Command, e.Summary())
+		if e.Error != "" {
+			fmt.Printf("    failed: %s\n", e.Error)
+		}
+		if !historyVerbose {
+			continue
+		}
+		for _, op := range e.Operations {
+			fmt.Printf("    %s\n", describeOperation(op))
+		}
+	}
+	return nil
+}
+
+func describeOperation(op journal.Operation) string {
+	var obj string
+	switch {
+	case op.FilterAfter != nil:
+		obj = op.FilterAfter.Criteria.ToGmailSearch()
+	case op.FilterBefore != nil:
+		obj = op.FilterBefore.Criteria.ToGmailSearch()
+	case op.LabelAfter != nil:
+		obj = op.LabelAfter.Name
+	case op.LabelBefore != nil:
+		obj = op.LabelBefore.Name
+	}
+	if op.ID != "" {
+		obj = fmt.Sprintf("%s [%s]", obj, op.ID)
+	}
+	return fmt.Sprintf("%s %s", op.Kind, obj)
+}
+
+// journalPath returns the path of the journal of the changes made to Gmail.
+func journalPath() string {
+	return path.Join(cfgDir, "journal.jsonl")
+}
+
+// applyWithJournal applies the diff, recording the operations executed in
+// the journal, even if the apply fails midway. The upstream settings are
+// backed up before applying, according to the given retention.
+func applyWithJournal(command string, diff papply.ConfigDiff, gmailapi papply.API, allowRemoveLabels bool, limits papply.Limits, retention backup.Retention) error {
+	if err := backupUpstream(diff.UpstreamConfig, retention); err != nil {
+		return err
+	}
+
+	rec := journal.NewRecorder(gmailapi, diff.UpstreamConfig)
+	err := papply.Apply(diff, rec, allowRemoveLabels, limits)
+	if len(rec.Operations()) == 0 {
+		return err
+	}
+
+	entry := journal.Entry{
+		Time:       time.Now(),
+		Command:    command,
+		Operations: rec.Operations(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if jerr := journal.Append(journalPath(), entry); jerr != nil {
+		stderrPrintf("Warning: the changes could not be recorded: %v\n", jerr)
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mbrt/gmailctl/internal/engine/lint"
+)
+
+var (
+	lintFilename   string
+	lintSeverities map[string]string
+	lintMaxFilters int
+	lintListChecks bool
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the configuration for likely mistakes",
+	Long: `The lint command analyses the configuration, looking for
+likely mistakes, like duplicate or redundant rules, labels that are
+used but not declared, or contradictory actions.
+
+Every check reports its findings with a severity (info, warning or
+error), which can be changed with --severity. Setting it to 'off'
+disables the check. The command fails if any error is found.
+
+Use --list-checks to see all the checks with their default severity.
+
+By default lint uses the configuration file inside the config
+directory [config.jsonnet].`,
+	Example: `gmailctl lint --severity unused-label=off,covered-rule=error`,
+	Run: func(*cobra.Command, []string) {
+		if lintListChecks {
+			listLintChecks()
+			return
+		}
+		f := lintFilename
+		if f == "" {
+			f = configFilenameFromDir(cfgDir)
+		}
+		if err := lintConfig(f); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	// Flags and configuration settings
+	lintCmd.PersistentFlags().StringVarP(&lintFilename, "filename", "f", "", "configuration file")
+	lintCmd.Flags().StringToStringVar(&lintSeverities, "severity", nil, "override the severity of checks (e.g. unused-label=off)")
+	lintCmd.Flags().IntVar(&lintMaxFilters, "max-filters", lint.DefaultMaxFilters, "maximum number of Gmail filters a rule can be split into")
+	lintCmd.Flags().BoolVar(&lintListChecks, "list-checks", false, "list all the available checks and exit")
+}
+
+func listLintChecks() {
+	for _, c := range lint.Checks() {
+		fmt.Printf("%-22s %-8s %s\n", c.Name, c.Severity, c.Description)
+	}
+}
+
+func lintConfig(path string) error {
+	opts := lint.Options{
+		Severities: map[string]lint.Severity{},
+		MaxFilters: lintMaxFilters,
+	}
+	for name, s := range lintSeverities {
+		sev, err := lint.ParseSeverity(s)
+		if err != nil {
+			return fmt.Errorf("invalid severity for check %q: %w", name, err)
+		}
+		opts.Severities[name] = sev
+	}
+
+	parseRes, err := parseConfig(path, "", false)
+	if err != nil {
+		return err
+	}
+	findings, err := lint.Lint(parseRes.Res.Rules, parseRes.Res.Labels, opts)
+	if err != nil {
+		return err
+	}
+
+	numErrors := 0
+	for _, f := range findings {
+		fmt.Println(f)
+		if f.Severity == lint.SeverityError {
+			numErrors++
+		}
+	}
+	if numErrors > 0 {
+		return fmt.Errorf("%d errors found", numErrors)
+	}
+	return nil
+}
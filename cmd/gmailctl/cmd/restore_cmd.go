@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/backup"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+var (
+	restoreList          bool
+	restoreYes           bool
+	restoreDiffContext   int
+	restoreForceMassDel  bool
+	restoreForceNonempty bool
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore [snapshot]",
+	Short: "Restore Gmail settings from a backup",
+	Long: `The restore command brings the Gmail filters and labels back
+to a snapshot, taken automatically before every apply.
+
+The snapshot is the name of a backup in the backups directory
+of the config (see --list), or the path of a snapshot file. The
+changes needed are shown, and applied after confirmation.
+
+Labels created after the snapshot are removed, so the messages
+having them lose the label. Labels that still have messages are
+only removed with --force-nonempty.
+
+The limits set in the 'apply' settings of the config are enforced,
+and restore refuses to delete most of the filters in Gmail, unless
+--force-mass-deletion is given.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		var err error
+		switch {
+		case restoreList:
+			err = listBackups()
+		case len(args) == 0:
+			err = errors.New("missing snapshot to restore (see --list)")
+		default:
+			err = restore(args[0])
+		}
+		if err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	// Flags and configuration settings
+	restoreCmd.Flags().BoolVarP(&restoreList, "list", "l", false, "list the available snapshots")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "don't ask for confirmation, just apply")
+	restoreCmd.Flags().IntVar(&restoreDiffContext, "diff-context", papply.DefaultContextLines, "number of lines of filter diff context to show")
+	restoreCmd.Flags().BoolVar(&restoreForceNonempty, "force-nonempty", false, "allow removing labels that still have messages")
+	restoreCmd.Flags().BoolVar(&restoreForceMassDel, "force-mass-deletion", false, "allow deleting most of the filters in Gmail")
+}
+
+func listBackups() error {
+	snaps, err := backup.List(backupsDir())
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		fmt.Println("No backups have been saved.")
+		return nil
+	}
+	for _, s := range snaps {
+		fmt.Printf("%s  %s\n", s.Name, s.Time.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+func restore(snapshot string) error {
+	snapPath, err := snapshotPath(snapshot)
+	if err != nil {
+		return err
+	}
+	target, err := backup.Load(snapPath)
+	if err != nil {
+		return err
+	}
+	settings, err := applySettings()
+	if err != nil {
+		return err
+	}
+
+	gmailapi, err := openAPI()
+	if err != nil {
+		return configurationError(fmt.Errorf("cannot connect to Gmail: %w", err))
+	}
+	upstream, err := upstreamConfig(gmailapi)
+	if err != nil {
+		return err
+	}
+
+	diff, err := papply.Diff(target, upstream, false, restoreDiffContext, shouldUseColorDiff())
+	if err != nil {
+		return fmt.Errorf("cannot compare upstream with the snapshot: %w", err)
+	}
+	if diff.Empty() {
+		fmt.Println("Gmail settings already match the snapshot.")
+		return nil
+	}
+
+	fmt.Printf("Restoring %s requires the following changes to your settings:\n\n%s\n", snapshot, diff)
+	fmt.Printf("Summary: %s\n\n", diff.Summary())
+
+	if err := diff.Validate(); err != nil {
+		return err
+	}
+	if err := checkLabelRemovals(diff, gmailapi, restoreForceNonempty); err != nil {
+		return err
+	}
+
+	limits := papply.LimitsFromConfig(settings)
+	limits.AllowMassDeletion = restoreForceMassDel
+	if err := checkLimits(diff, limits, true); err != nil {
+		return err
+	}
+
+	if !restoreYes && !askYN("Do you want to apply them?") {
+		return nil
+	}
+
+	fmt.Println("Applying the changes...")
+	return applyWithJournal("restore "+snapshot, diff, gmailapi, true, limits,
+		backup.RetentionFromConfig(settings))
+}
+
+// snapshotPath returns the path of the snapshot with the given name, or
+// the snapshot itself if it's an existing file.
+func snapshotPath(snapshot string) (string, error) {
+	if _, err := os.Stat(snapshot); err == nil {
+		return snapshot, nil
+	}
+	p := path.Join(backupsDir(), strings.TrimSuffix(snapshot, ".json")+".json")
+	if _, err := os.Stat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", errors.WithDetails(fmt.Errorf("snapshot %q not found", snapshot),
+				"Use 'gmailctl restore --list' to list the available snapshots.")
+		}
+		return "", err
+	}
+	return p, nil
+}
+
+// backupsDir returns the directory containing the backups of the Gmail
+// settings.
+func backupsDir() string {
+	return path.Join(cfgDir, "backups")
+}
+
+// backupUpstream saves a snapshot of the upstream settings and removes the
+// old ones, according to the retention.
+func backupUpstream(upstream papply.GmailConfig, retention backup.Retention) error {
+	if !retention.Enabled() {
+		return nil
+	}
+	now := time.Now()
+	if _, err := backup.Save(backupsDir(), upstream, now); err != nil {
+		return errors.WithDetails(fmt.Errorf("cannot back up Gmail settings: %w", err),
+			"No changes have been made. Backups can be disabled by setting\n"+
+				"'apply.backups.keep' to 0 in the config.")
+	}
+	if err := backup.Prune(backupsDir(), retention, now); err != nil {
+		stderrPrintf("Warning: old backups could not be removed: %v\n", err)
+	}
+	return nil
+}
@@ -1,8 +1,26 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"os"
 
-var testFilename string
+	"github.com/spf13/cobra"
+
+	"github.com/mbrt/gmailctl/internal/engine/cfgtest"
+	"github.com/mbrt/gmailctl/internal/engine/rimport"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+var (
+	testFilename      string
+	testVerifyExport  bool
+	testGenerate      bool
+	testGenerateStubs bool
+)
+
+const generatedTestsHeader = `// Tests generated by 'gmailctl test --generate'.
+// Paste them into the 'tests' field of your config.
+`
 
 // testCmd represents the test command
 var testCmd = &cobra.Command{
@@ -18,7 +36,17 @@ Warning: This command is still experimental.
 
 List of unsupported constructs:
 * Escaped expressions (pkg/config/v1alpha3/FilterNode.IsEscaped);
-* Raw queries (pkg/config/v1alpha3/FilterNode.Query).
+* Raw queries (pkg/config/v1alpha3/FilterNode.Query) using operators
+  other than: deliveredto, filename, has:attachment, larger, smaller,
+  size, older_than, newer_than, before, after and rfc822msgid.
+
+With --verify-export, the filters generated for Gmail are parsed
+back and evaluated on all the test messages, to make sure that
+Gmail is going to match the same messages as the tests.
+
+With --generate, messages that should and should not match each
+rule are synthesized and verified. Add --stubs to print them as
+Jsonnet tests, ready to be pasted in the configuration.
 
 By default test uses the configuration file inside the config
 directory [config.jsonnet].`,
@@ -38,9 +66,50 @@ func init() {
 
 	// Flags and configuration settings
 	testCmd.PersistentFlags().StringVarP(&testFilename, "filename", "f", "", "configuration file")
+	testCmd.Flags().BoolVar(&testVerifyExport, "verify-export", false, "check that the generated filters agree with the tests")
+	testCmd.Flags().BoolVar(&testGenerate, "generate", false, "generate test messages for every rule")
+	testCmd.Flags().BoolVar(&testGenerateStubs, "stubs", false, "print the generated tests as Jsonnet (requires --generate)")
 }
 
 func test(path string) error {
-	_, err := parseConfig(path, "", true)
-	return err
+	if testGenerateStubs && !testGenerate {
+		return errors.New("--stubs requires --generate")
+	}
+	parseRes, err := parseConfig(path, "", true)
+	if err != nil {
+		return err
+	}
+	if testVerifyExport {
+		if err := cfgtest.VerifyExport(parseRes.Res.Rules, parseRes.Config.Tests); err != nil {
+			stderrPrintf("%+v\n", err)
+			return fmt.Errorf("%d disagreements between the generated filters and the tests",
+				len(errors.Errors(err)))
+		}
+	}
+	if testGenerate {
+		return generateTests(parseRes)
+	}
+	return nil
+}
+
+func generateTests(parseRes parseResult) error {
+	tests, err := cfgtest.GenerateTests(parseRes.Res.Rules)
+	if tests == nil && err != nil {
+		return fmt.Errorf("cannot generate tests: %w", err)
+	}
+	if err != nil {
+		stderrPrintf("WARNING: %d problems found while generating tests:\n", len(errors.Errors(err)))
+		stderrPrintf("%+v\n\n", err)
+	}
+	if testGenerateStubs {
+		return rimport.MarshalJsonnet(tests, os.Stdout, generatedTestsHeader)
+	}
+
+	numMessages := 0
+	for _, t := range tests {
+		numMessages += len(t.Messages)
+		fmt.Printf("%s: %d messages\n", t.Name, len(t.Messages))
+	}
+	fmt.Printf("Generated and verified %d tests with %d messages.\n", len(tests), numMessages)
+	return nil
 }
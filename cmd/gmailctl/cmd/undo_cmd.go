@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	papply "github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/backup"
+	"github.com/mbrt/gmailctl/internal/engine/journal"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+var (
+	undoYes           bool
+	undoDiffContext   int
+	undoForceMassDel  bool
+	undoForceNonempty bool
+)
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo [N]",
+	Short: "Undo changes previously applied to Gmail",
+	Long: `The undo command reverts the changes made by an apply, as
+recorded in the journal (see 'gmailctl history'). By default the
+last apply is undone, otherwise the one with the given number.
+
+Created filters and labels are removed, deleted ones are recreated
+and modified labels are restored. Objects that changed again after
+that apply are left as they are. The changes are shown, and applied
+after confirmation.
+
+The limits set in the 'apply' settings of the config are enforced,
+and undo refuses to delete most of the filters in Gmail, unless
+--force-mass-deletion is given. Labels that still have messages
+are only deleted with --force-nonempty.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		n := 0
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				fatal(fmt.Errorf("invalid apply number %q", args[0]))
+			}
+		}
+		if err := undo(n); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	// Flags and configuration settings
+	undoCmd.Flags().BoolVarP(&undoYes, "yes", "y", false, "don't ask for confirmation, just apply")
+	undoCmd.Flags().IntVar(&undoDiffContext, "diff-context", papply.DefaultContextLines, "number of lines of filter diff context to show")
+	undoCmd.Flags().BoolVar(&undoForceMassDel, "force-mass-deletion", false, "allow deleting most of the filters in Gmail")
+	undoCmd.Flags().BoolVar(&undoForceNonempty, "force-nonempty", false, "allow removing labels that still have messages")
+}
+
+// undo reverts the n-th entry of the journal, or the last one if n is 0.
+func undo(n int) error {
+	entries, err := journal.Read(journalPath())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New("no changes have been recorded")
+	}
+	if n == 0 {
+		n = len(entries)
+	}
+	if n > len(entries) {
+		return fmt.Errorf("apply #%d not found: only %d have been recorded", n, len(entries))
+	}
+	settings, err := applySettings()
+	if err != nil {
+		return err
+	}
+
+	gmailapi, err := openAPI()
+	if err != nil {
+		return configurationError(fmt.Errorf("cannot connect to Gmail: %w", err))
+	}
+	upstream, err := upstreamConfig(gmailapi)
+	if err != nil {
+		return err
+	}
+
+	target := journal.Revert(entries[n-1], upstream)
+	diff, err := papply.Diff(target, upstream, false, undoDiffContext, shouldUseColorDiff())
+	if err != nil {
+		return fmt.Errorf("cannot compare upstream with the reverted config: %w", err)
+	}
+	if diff.Empty() {
+		fmt.Println("Nothing to undo.")
+		return nil
+	}
+
+	fmt.Printf("Undoing apply #%d requires the following changes to your settings:\n\n%s\n", n, diff)
+	fmt.Printf("Summary: %s\n\n", diff.Summary())
+
+	if err := diff.Validate(); err != nil {
+		return err
+	}
+	if err := checkLabelRemovals(diff, gmailapi, undoForceNonempty); err != nil {
+		return err
+	}
+
+	limits := papply.LimitsFromConfig(settings)
+	limits.AllowMassDeletion = undoForceMassDel
+	if err := checkLimits(diff, limits, true); err != nil {
+		return err
+	}
+
+	if !undoYes && !askYN("Do you want to apply them?") {
+		return nil
+	}
+
+	fmt.Println("Applying the changes...")
+	return applyWithJournal(fmt.Sprintf("undo #%d", n), diff, gmailapi, true, limits,
+		backup.RetentionFromConfig(settings))
+}
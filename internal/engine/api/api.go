@@ -53,36 +53,38 @@ func (g *GmailAPI) ListFilters() (filter.Filters, error) {
 }
 
 // DeleteFilters deletes all the given filter IDs.
-func (g *GmailAPI) DeleteFilters(ids []string) error {
-	for _, id := range ids {
+func (g *GmailAPI) DeleteFilters(ids []string) ([]string, error) {
+	for i, id := range ids {
 		err := g.service.Users.Settings.Filters.Delete(gmailUser, id).Do(g.opts...)
 		if err != nil {
-			return fmt.Errorf("deleting filter %q: %w", id, annotateError(err))
+			return ids[:i], fmt.Errorf("deleting filter %q: %w", id, annotateError(err))
 		}
 	}
-	return nil
+	return ids, nil
 }
 
-// AddFilters creates the given filters.
-func (g *GmailAPI) AddFilters(fs filter.Filters) error {
+// AddFilters creates the given filters and returns their IDs.
+func (g *GmailAPI) AddFilters(fs filter.Filters) ([]string, error) {
 	lmap, err := g.getLabelMap()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	gfilters, err := api.Export(fs, lmap)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var ids []string
 	for i, gfilter := range gfilters {
-		_, err = g.service.Users.Settings.Filters.Create(gmailUser, gfilter).Do(g.opts...)
+		created, err := g.service.Users.Settings.Filters.Create(gmailUser, gfilter).Do(g.opts...)
 		if err != nil {
-			return fmt.Errorf("creating filter %d: %w", i, annotateError(err))
+			return ids, fmt.Errorf("creating filter %d: %w", i, annotateError(err))
 		}
+		ids = append(ids, created.Id)
 	}
 
-	return nil
+	return ids, nil
 }
 
 // ListLabels lists the user labels.
@@ -109,52 +111,67 @@ func (g *GmailAPI) ListLabels() (label.Labels, error) {
 		}
 
 		res = append(res, label.Label{
-			ID:    lb.Id,
-			Name:  lb.Name,
-			Color: color,
+			ID:          lb.Id,
+			Name:        lb.Name,
+			Color:       color,
+			LabelList:   labelListVisibilities[lb.LabelListVisibility],
+			MessageList: label.Visibility(lb.MessageListVisibility),
 		})
 	}
 
 	return res, nil
 }
 
+// LabelUsage returns the number of messages and threads with the given
+// label ID.
+func (g *GmailAPI) LabelUsage(id string) (label.Usage, error) {
+	lb, err := g.service.Users.Labels.Get(gmailUser, id).Do(g.opts...)
+	if err != nil {
+		return label.Usage{}, fmt.Errorf("getting label %q: %w", id, annotateError(err))
+	}
+	return label.Usage{Messages: lb.MessagesTotal, Threads: lb.ThreadsTotal}, nil
+}
+
 // DeleteLabels deletes all the given label IDs.
-func (g *GmailAPI) DeleteLabels(ids []string) error {
-	for _, id := range ids {
+func (g *GmailAPI) DeleteLabels(ids []string) ([]string, error) {
+	for i, id := range ids {
 		err := g.service.Users.Labels.Delete(gmailUser, id).Do(g.opts...)
 		if err != nil {
-			return fmt.Errorf("deleting label %q: %w", id, annotateError(err))
+			return ids[:i], fmt.Errorf("deleting label %q: %w", id, annotateError(err))
 		}
 	}
-	return nil
-
+	return ids, nil
 }
 
-// AddLabels creates the given labels.
-func (g *GmailAPI) AddLabels(lbs label.Labels) error {
+// AddLabels creates the given labels and returns their IDs.
+func (g *GmailAPI) AddLabels(lbs label.Labels) ([]string, error) {
+	var ids []string
 	for _, lb := range lbs {
-		_, err := g.service.Users.Labels.Create(gmailUser, labelToGmailAPI(lb)).Do(g.opts...)
+		created, err := g.service.Users.Labels.Create(gmailUser, labelToGmailAPI(lb)).Do(g.opts...)
 		if err != nil {
-			return annotateError(fmt.Errorf("creating label %q: %w", lb.Name, err))
+			return ids, annotateError(fmt.Errorf("creating label %q: %w", lb.Name, err))
 		}
+		ids = append(ids, created.Id)
 	}
-	return nil
+	return ids, nil
 }
 
 // UpdateLabels modifies the given labels.
 //
 // The label ID is required for the edit to be successful.
-func (g *GmailAPI) UpdateLabels(lbs label.Labels) error {
+func (g *GmailAPI) UpdateLabels(lbs label.Labels) ([]string, error) {
+	var ids []string
 	for _, lb := range lbs {
 		if lb.ID == "" {
-			return fmt.Errorf("label %q has empty ID", lb.Name)
+			return ids, fmt.Errorf("label %q has empty ID", lb.Name)
 		}
 		_, err := g.service.Users.Labels.Patch(gmailUser, lb.ID, labelToGmailAPI(lb)).Do(g.opts...)
 		if err != nil {
-			return annotateError(fmt.Errorf("patching label %q: %w", lb.Name, err))
+			return ids, annotateError(fmt.Errorf("patching label %q: %w", lb.Name, err))
 		}
+		ids = append(ids, lb.ID)
 	}
-	return nil
+	return ids, nil
 }
 
 func (g *GmailAPI) getLabelMap() (api.LabelMap, error) {
@@ -165,6 +182,14 @@ func (g *GmailAPI) getLabelMap() (api.LabelMap, error) {
 	return api.NewLabelMap(labels), nil
 }
 
+// labelListVisibilities maps the Gmail label list visibilities to ours. The
+// message list ones are the same.
+var labelListVisibilities = map[string]label.Visibility{
+	"labelShow":         label.Show,
+	"labelHide":         label.Hide,
+	"labelShowIfUnread": label.ShowIfUnread,
+}
+
 func labelToGmailAPI(lb label.Label) *gmail.Label {
 	var color *gmail.LabelColor
 	if lb.Color != nil {
@@ -173,10 +198,19 @@ func labelToGmailAPI(lb label.Label) *gmail.Label {
 			TextColor:       lb.Color.Text,
 		}
 	}
-	return &gmail.Label{
-		Name:  lb.Name,
-		Color: color,
+	res := &gmail.Label{
+		Name:                  lb.Name,
+		Color:                 color,
+		MessageListVisibility: string(lb.MessageList),
+	}
+	// Unspecified visibilities are left empty, so that they are not
+	// changed.
+	for k, v := range labelListVisibilities {
+		if v == lb.LabelList {
+			res.LabelListVisibility = k
+		}
 	}
+	return res
 }
 
 func annotateError(err error) error {
@@ -2,7 +2,6 @@ package apply
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
@@ -18,12 +17,20 @@ const DefaultContextLines = 5
 type GmailConfig struct {
 	Labels  label.Labels
 	Filters filter.Filters
+	// Unmanaged matches the upstream filters and labels that are not
+	// managed by this config, if any.
+	Unmanaged Unmanaged
 }
 
 // ConfigParseRes represents the result of a config parse.
 type ConfigParseRes struct {
 	GmailConfig
 	Rules []parser.Rule
+	// Limits are the limits to the changes set in the config.
+	Limits Limits
+	// Warnings are the problems found in the config, which don't prevent
+	// using it.
+	Warnings []string
 }
 
 // FromConfig creates a GmailConfig from a parsed configuration file.
@@ -39,7 +46,17 @@ func FromConfig(cfg v1alpha3.Config) (ConfigParseRes, error) {
 	if err != nil {
 		return res, fmt.Errorf("exporting to filters: %w", err)
 	}
-	res.Labels = label.FromConfig(cfg.Labels)
+	var orphans []string
+	res.Labels, orphans = label.FromConfig(cfg.Labels)
+	for _, o := range orphans {
+		res.Warnings = append(res.Warnings,
+			fmt.Sprintf("label %q is declared without its parent %q, which is added as well", o, label.Parent(o)))
+	}
+	res.Limits = LimitsFromConfig(cfg.Apply)
+	res.Unmanaged, err = UnmanagedFromConfig(cfg.Unmanaged)
+	if err != nil {
+		return res, fmt.Errorf("parsing unmanaged section: %w", err)
+	}
 
 	return res, nil
 }
@@ -73,22 +90,24 @@ func FromAPI(api FetchAPI) (GmailConfig, error) {
 // ConfigDiff contains the difference between local and upstream configuration,
 // including both labels and filters.
 //
-// For validation purposes, the local config is also kept.
+// For validation purposes, the local and upstream configs are also kept.
 type ConfigDiff struct {
 	FiltersDiff filter.FiltersDiff
 	LabelsDiff  label.LabelsDiff
 
-	LocalConfig GmailConfig
+	LocalConfig    GmailConfig
+	UpstreamConfig GmailConfig
 }
 
 func (d ConfigDiff) String() string {
 	var res []string
 
-	if !d.FiltersDiff.Empty() {
+	// Ignored filters and labels are shown even without changes.
+	if !d.FiltersDiff.Empty() || len(d.FiltersDiff.Ignored) > 0 {
 		res = append(res, "Filters:")
 		res = append(res, d.FiltersDiff.String())
 	}
-	if !d.LabelsDiff.Empty() {
+	if !d.LabelsDiff.Empty() || len(d.LabelsDiff.Ignored) > 0 {
 		res = append(res, "Labels:")
 		res = append(res, d.LabelsDiff.String())
 	}
@@ -96,6 +115,18 @@ func (d ConfigDiff) String() string {
 	return strings.Join(res, "\n")
 }
 
+// Summary returns the number of changes in the diff, by kind.
+func (d ConfigDiff) Summary() string {
+	var res []string
+	if !d.FiltersDiff.Empty() {
+		res = append(res, fmt.Sprintf("filters: %s", d.FiltersDiff.Summary()))
+	}
+	if !d.LabelsDiff.Empty() {
+		res = append(res, fmt.Sprintf("labels: %s", d.LabelsDiff.Summary()))
+	}
+	return strings.Join(res, "; ")
+}
+
 // Empty returns whether the diff contains no changes.
 func (d ConfigDiff) Empty() bool {
 	return d.FiltersDiff.Empty() && d.LabelsDiff.Empty()
@@ -109,7 +140,7 @@ func (d ConfigDiff) Validate() error {
 	if err := d.LocalConfig.Labels.Validate(); err != nil {
 		return fmt.Errorf("validating labels: %w", err)
 	}
-	if err := label.Validate(d.LabelsDiff, d.LocalConfig.Filters); err != nil {
+	if err := label.Validate(d.LabelsDiff, d.LocalConfig.Labels, d.LocalConfig.Filters); err != nil {
 		return fmt.Errorf("invalid labels diff: %w", err)
 	}
 	return nil
@@ -118,18 +149,35 @@ func (d ConfigDiff) Validate() error {
 // Diff computes the diff between local and upstream configuration.
 func Diff(local, upstream GmailConfig, debugInfo bool, contextLines int, colorize bool) (ConfigDiff, error) {
 	res := ConfigDiff{
-		LocalConfig: local,
+		LocalConfig:    local,
+		UpstreamConfig: upstream,
 	}
 	var err error
 
-	res.FiltersDiff, err = filter.Diff(upstream.Filters, local.Filters, debugInfo, contextLines, colorize)
+	// Filters refer to labels by ID in Gmail, so they follow renamed
+	// labels without changes.
+	upstreamFilters := upstream.Filters
+	if len(local.Labels) > 0 {
+		upstreamFilters = renameFilterLabels(upstream.Filters, label.Renames(upstream.Labels, local.Labels))
+	}
+	res.FiltersDiff, err = filter.Diff(upstreamFilters, local.Filters, filter.DiffOptions{
+		Unmanaged:    local.Unmanaged.MatchFilter,
+		DebugInfo:    debugInfo,
+		ContextLines: contextLines,
+		Colorize:     colorize,
+	})
 	if err != nil {
 		return res, fmt.Errorf("cannot compute filters diff: %w", err)
 	}
 
 	if len(local.Labels) > 0 {
-		// LabelsDiff management opted-in
-		res.LabelsDiff, err = label.Diff(upstream.Labels, local.Labels, colorize)
+		// LabelsDiff management opted-in.
+		// Labels used by unmanaged filters are unmanaged as well, as
+		// removing them would break the filters.
+		unmanagedLabel := func(l label.Label) bool {
+			return local.Unmanaged.MatchLabel(l) || res.FiltersDiff.Ignored.HasLabel(l.Name)
+		}
+		res.LabelsDiff, err = label.Diff(upstream.Labels, local.Labels, unmanagedLabel, colorize)
 		if err != nil {
 			return res, fmt.Errorf("cannot compute labels diff: %w", err)
 		}
@@ -138,35 +186,66 @@ func Diff(local, upstream GmailConfig, debugInfo bool, contextLines int, coloriz
 	return res, nil
 }
 
+// renameFilterLabels returns the filters, with the labels renamed according
+// to the given map from old to new names.
+func renameFilterLabels(fs filter.Filters, renames map[string]string) filter.Filters {
+	if len(renames) == 0 {
+		return fs
+	}
+	res := make(filter.Filters, len(fs))
+	for i, f := range fs {
+		if n, ok := renames[f.Action.AddLabel]; ok {
+			f.Action.AddLabel = n
+		}
+		res[i] = f
+	}
+	return res
+}
+
 // API provides access to Gmail APIs.
+//
+// Objects are changed one at a time. Every method returns the IDs of the
+// objects changed successfully, in order, including when an error stops the
+// remaining ones. For created objects these are the IDs assigned by Gmail.
 type API interface {
-	AddLabels(lbs label.Labels) error
-	AddFilters(fs filter.Filters) error
-	UpdateLabels(lbs label.Labels) error
-	DeleteFilters(ids []string) error
-	DeleteLabels(ids []string) error
+	AddLabels(lbs label.Labels) ([]string, error)
+	AddFilters(fs filter.Filters) ([]string, error)
+	UpdateLabels(lbs label.Labels) ([]string, error)
+	DeleteFilters(ids []string) ([]string, error)
+	DeleteLabels(ids []string) ([]string, error)
 }
 
 // Apply applies the changes identified by the diff to the remote configuration.
-func Apply(d ConfigDiff, api API, allowRemoveLabels bool) error {
+//
+// No changes are made if the diff exceeds the given limits.
+func Apply(d ConfigDiff, api API, allowRemoveLabels bool, limits Limits) error {
+	if err := limits.Check(d, allowRemoveLabels); err != nil {
+		return err
+	}
+
 	// In order to prevent not found errors, the sequence has to be:
 	//
+	// - modify labels
 	// - add new labels
 	// - add new filters
-	// - modify labels
 	// - remove filters
 	// - remove labels
+	//
+	// Labels are modified first, so that renamed labels free their old
+	// names and new filters can already use their new names.
 
+	if err := updateLabels(d.LabelsDiff.Modified, api); err != nil {
+		return fmt.Errorf("updating labels: %w", err)
+	}
 	if err := addLabels(d.LabelsDiff.Added, api); err != nil {
 		return fmt.Errorf("creating labels: %w", err)
 	}
-	if err := addFilters(d.FiltersDiff.Added, api); err != nil {
+	// Gmail doesn't support updating filters, so the modified ones are
+	// replaced by adding the new version and removing the old one.
+	if err := addFilters(d.FiltersDiff.AllAdded(), api); err != nil {
 		return fmt.Errorf("creating filters: %w", err)
 	}
-	if err := updateLabels(d.LabelsDiff.Modified, api); err != nil {
-		return fmt.Errorf("updating labels: %w", err)
-	}
-	if err := removeFilters(d.FiltersDiff.Removed, api); err != nil {
+	if err := removeFilters(d.FiltersDiff.AllRemoved(), api); err != nil {
 		return fmt.Errorf("deleting filters: %w", err)
 	}
 
@@ -184,18 +263,17 @@ func addLabels(lbs label.Labels, api API) error {
 	if len(lbs) == 0 {
 		return nil
 	}
-	// If we have nested labels we should create them in the right order.
-	// As a quick hack, we could sort them by the length of the name,
-	// because a label is strictly longer than its prefixes.
-	sort.Sort(byLen(lbs))
-	return api.AddLabels(lbs)
+	// Parents have to be created before the labels nested into them.
+	_, err := api.AddLabels(label.ParentsFirst(lbs))
+	return err
 }
 
 func addFilters(ls filter.Filters, api API) error {
-	if len(ls) > 0 {
-		return api.AddFilters(ls)
+	if len(ls) == 0 {
+		return nil
 	}
-	return nil
+	_, err := api.AddFilters(ls)
+	return err
 }
 
 func updateLabels(ms []label.ModifiedLabel, api API) error {
@@ -204,11 +282,13 @@ func updateLabels(ms []label.ModifiedLabel, api API) error {
 	}
 	var lbs label.Labels
 	for _, m := range ms {
-		label := m.New
-		label.ID = m.Old.ID
-		lbs = append(lbs, label)
+		l := m.New
+		l.ID = m.Old.ID
+		lbs = append(lbs, l)
 	}
-	return api.UpdateLabels(lbs)
+	// Renamed parents come before the labels nested into them.
+	_, err := api.UpdateLabels(label.ParentsFirst(lbs))
+	return err
 }
 
 func removeFilters(ls filter.Filters, api API) error {
@@ -219,36 +299,20 @@ func removeFilters(ls filter.Filters, api API) error {
 	for i, f := range ls {
 		ids[i] = f.ID
 	}
-	return api.DeleteFilters(ids)
+	_, err := api.DeleteFilters(ids)
+	return err
 }
 
 func removeLabels(lbs label.Labels, api API) error {
 	if len(lbs) == 0 {
 		return nil
 	}
-	// If we have nested labels we should remove them in the right order.
-	// As a quick hack, we could sort them by the length of the name,
-	// because a label is strictly longer than its prefixes.
-	sort.Sort(byLen(lbs))
-
-	// Delete in reverse order
+	// Nested labels are removed before their parents.
+	sorted := label.ParentsFirst(lbs)
 	var ids []string
-	for i := len(lbs) - 1; i >= 0; i-- {
-		ids = append(ids, lbs[i].ID)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		ids = append(ids, sorted[i].ID)
 	}
-	return api.DeleteLabels(ids)
-}
-
-type byLen label.Labels
-
-func (b byLen) Len() int {
-	return len(b)
-}
-
-func (b byLen) Less(i, j int) bool {
-	return len(b[i].Name) < len(b[j].Name)
-}
-
-func (b byLen) Swap(i, j int) {
-	b[i], b[j] = b[j], b[i]
+	_, err := api.DeleteLabels(ids)
+	return err
 }
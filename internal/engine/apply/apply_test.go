@@ -0,0 +1,84 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func TestDiffRenamedLabel(t *testing.T) {
+	upstream := GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "work"}},
+		Filters: filter.Filters{
+			{ID: "f1", Criteria: filter.Criteria{From: "boss@b.com"}, Action: filter.Actions{AddLabel: "work"}},
+		},
+	}
+	local := GmailConfig{
+		Labels: label.Labels{{Name: "job", PreviousNames: []string{"work"}}},
+		Filters: filter.Filters{
+			{Criteria: filter.Criteria{From: "boss@b.com"}, Action: filter.Actions{AddLabel: "job"}},
+			{Criteria: filter.Criteria{From: "hr@b.com"}, Action: filter.Actions{AddLabel: "job"}},
+		},
+	}
+
+	d, err := Diff(local, upstream, false, DefaultContextLines, false)
+	require.Nil(t, err)
+	require.Nil(t, d.Validate())
+	// The label is renamed and the existing filter follows it.
+	assert.Equal(t, "filters: 1 added, 0 modified, 0 removed; labels: 0 added, 1 modified, 0 removed", d.Summary())
+	assert.Equal(t, "l1", d.LabelsDiff.Modified[0].Old.ID)
+	assert.Equal(t, "job", d.LabelsDiff.Modified[0].New.Name)
+	// The upstream config is left as it is.
+	assert.Equal(t, "work", d.UpstreamConfig.Filters[0].Action.AddLabel)
+
+	// The new filter can't be selected without the rename.
+	_, err = d.Select(func(c ConfigDiff) bool { return c.LabelsDiff.Empty() })
+	assert.NotNil(t, err)
+
+	res, err := d.Select(func(ConfigDiff) bool { return true })
+	require.Nil(t, err)
+	assert.Nil(t, res.Validate())
+	for _, f := range res.LocalConfig.Filters {
+		assert.Equal(t, "job", f.Action.AddLabel)
+	}
+}
+
+type labelOrderAPI struct {
+	recordingAPI
+	added   []string
+	deleted []string
+}
+
+func (a *labelOrderAPI) AddLabels(lbs label.Labels) ([]string, error) {
+	for _, l := range lbs {
+		a.added = append(a.added, l.Name)
+	}
+	return nil, nil
+}
+
+func (a *labelOrderAPI) DeleteLabels(ids []string) ([]string, error) {
+	a.deleted = append(a.deleted, ids...)
+	return ids, nil
+}
+
+func TestApplyLabelsOrder(t *testing.T) {
+	upstream := GmailConfig{
+		Labels: label.Labels{{ID: "1", Name: "old"}, {ID: "2", Name: "old/a/b"}, {ID: "3", Name: "old/a"}},
+	}
+	local := GmailConfig{
+		Labels: label.Labels{{Name: "new/x"}, {Name: "new/x/y"}, {Name: "new"}, {Name: "new-sibling"}},
+	}
+	d, err := Diff(local, upstream, false, DefaultContextLines, false)
+	require.Nil(t, err)
+	require.Nil(t, d.Validate())
+
+	api := &labelOrderAPI{}
+	require.Nil(t, Apply(d, api, true, NoLimits))
+	// Parents are created first and removed last.
+	assert.Equal(t, []string{"new", "new-sibling", "new/x", "new/x/y"}, api.added)
+	assert.Equal(t, []string{"2", "3", "1"}, api.deleted)
+}
@@ -0,0 +1,165 @@
+package apply
+
+import (
+	"encoding/json"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// MarshalJSON encodes the changes of the diff in JSON, for consumption by
+// other tools. The local config is not included.
+func (d ConfigDiff) MarshalJSON() ([]byte, error) {
+	res := jsonDiff{
+		Filters: jsonFiltersDiff{
+			Added:    jsonFilters(d.FiltersDiff.Added),
+			Removed:  jsonFilters(d.FiltersDiff.Removed),
+			Modified: []jsonModifiedFilter{},
+			Ignored:  jsonFilters(d.FiltersDiff.Ignored),
+		},
+		Labels: jsonLabelsDiff{
+			Added:    jsonLabels(d.LabelsDiff.Added),
+			Removed:  jsonLabels(d.LabelsDiff.Removed),
+			Modified: []jsonModifiedLabel{},
+			Ignored:  jsonLabels(d.LabelsDiff.Ignored),
+		},
+	}
+	for _, mf := range d.FiltersDiff.Modified {
+		res.Filters.Modified = append(res.Filters.Modified, jsonModifiedFilter{
+			Old: jsonFilter(mf.Old),
+			New: jsonFilter(mf.New),
+		})
+	}
+	for _, ml := range d.LabelsDiff.Modified {
+		res.Labels.Modified = append(res.Labels.Modified, jsonModifiedLabel{
+			Old: jsonLabel(ml.Old),
+			New: jsonLabel(ml.New),
+		})
+	}
+	return json.Marshal(res)
+}
+
+type jsonDiff struct {
+	Filters jsonFiltersDiff `json:"filters"`
+	Labels  jsonLabelsDiff  `json:"labels"`
+}
+
+type jsonFiltersDiff struct {
+	Added    []jsonFilterEntry    `json:"added"`
+	Removed  []jsonFilterEntry    `json:"removed"`
+	Modified []jsonModifiedFilter `json:"modified"`
+	Ignored  []jsonFilterEntry    `json:"ignored"`
+}
+
+type jsonFilterEntry struct {
+	ID       string       `json:"id,omitempty"`
+	Criteria jsonCriteria `json:"criteria"`
+	Actions  jsonActions  `json:"actions"`
+}
+
+type jsonModifiedFilter struct {
+	Old jsonFilterEntry `json:"old"`
+	New jsonFilterEntry `json:"new"`
+}
+
+type jsonCriteria struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Query   string `json:"query,omitempty"`
+}
+
+type jsonActions struct {
+	AddLabel         string `json:"addLabel,omitempty"`
+	Category         string `json:"category,omitempty"`
+	Archive          bool   `json:"archive,omitempty"`
+	Delete           bool   `json:"delete,omitempty"`
+	MarkImportant    bool   `json:"markImportant,omitempty"`
+	MarkNotImportant bool   `json:"markNotImportant,omitempty"`
+	MarkRead         bool   `json:"markRead,omitempty"`
+	MarkNotSpam      bool   `json:"markNotSpam,omitempty"`
+	Star             bool   `json:"star,omitempty"`
+	Forward          string `json:"forward,omitempty"`
+}
+
+type jsonLabelsDiff struct {
+	Added    []jsonLabelEntry    `json:"added"`
+	Removed  []jsonLabelEntry    `json:"removed"`
+	Modified []jsonModifiedLabel `json:"modified"`
+	Ignored  []jsonLabelEntry    `json:"ignored"`
+}
+
+type jsonLabelEntry struct {
+	ID          string     `json:"id,omitempty"`
+	Name        string     `json:"name"`
+	Color       *jsonColor `json:"color,omitempty"`
+	LabelList   string     `json:"labelList,omitempty"`
+	MessageList string     `json:"messageList,omitempty"`
+}
+
+type jsonColor struct {
+	Background string `json:"background"`
+	Text       string `json:"text"`
+}
+
+type jsonModifiedLabel struct {
+	Old jsonLabelEntry `json:"old"`
+	New jsonLabelEntry `json:"new"`
+}
+
+func jsonFilters(fs filter.Filters) []jsonFilterEntry {
+	// Always return a non-nil slice, to encode empty lists as [].
+	res := []jsonFilterEntry{}
+	for _, f := range fs {
+		res = append(res, jsonFilter(f))
+	}
+	return res
+}
+
+func jsonFilter(f filter.Filter) jsonFilterEntry {
+	return jsonFilterEntry{
+		ID: f.ID,
+		Criteria: jsonCriteria{
+			From:    f.Criteria.From,
+			To:      f.Criteria.To,
+			Subject: f.Criteria.Subject,
+			Query:   f.Criteria.Query,
+		},
+		Actions: jsonActions{
+			AddLabel:         f.Action.AddLabel,
+			Category:         string(f.Action.Category),
+			Archive:          f.Action.Archive,
+			Delete:           f.Action.Delete,
+			MarkImportant:    f.Action.MarkImportant,
+			MarkNotImportant: f.Action.MarkNotImportant,
+			MarkRead:         f.Action.MarkRead,
+			MarkNotSpam:      f.Action.MarkNotSpam,
+			Star:             f.Action.Star,
+			Forward:          f.Action.Forward,
+		},
+	}
+}
+
+func jsonLabels(ls label.Labels) []jsonLabelEntry {
+	res := []jsonLabelEntry{}
+	for _, l := range ls {
+		res = append(res, jsonLabel(l))
+	}
+	return res
+}
+
+func jsonLabel(l label.Label) jsonLabelEntry {
+	res := jsonLabelEntry{
+		ID:          l.ID,
+		Name:        l.Name,
+		LabelList:   string(l.LabelList),
+		MessageList: string(l.MessageList),
+	}
+	if l.Color != nil {
+		res.Color = &jsonColor{
+			Background: l.Color.Background,
+			Text:       l.Color.Text,
+		}
+	}
+	return res
+}
@@ -0,0 +1,98 @@
+package apply
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/gmail"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func TestDiffJSON(t *testing.T) {
+	d := ConfigDiff{
+		FiltersDiff: filter.FiltersDiff{
+			Added: filter.Filters{
+				{
+					Criteria: filter.Criteria{From: "a@b.com"},
+					Action:   filter.Actions{AddLabel: "work", Category: gmail.CategoryUpdates},
+				},
+			},
+			Removed: filter.Filters{
+				{
+					ID:       "abc",
+					Criteria: filter.Criteria{Query: "foo"},
+					Action:   filter.Actions{Archive: true},
+				},
+			},
+			Modified: []filter.ModifiedFilter{
+				{
+					Old: filter.Filter{
+						ID:       "def",
+						Criteria: filter.Criteria{To: "me"},
+						Action:   filter.Actions{Star: true},
+					},
+					New: filter.Filter{
+						Criteria: filter.Criteria{To: "me"},
+						Action:   filter.Actions{Star: true, MarkRead: true},
+					},
+				},
+			},
+		},
+		LabelsDiff: label.LabelsDiff{
+			Added:   label.Labels{{Name: "work"}},
+			Ignored: label.Labels{{ID: "l2", Name: "shared"}},
+			Modified: []label.ModifiedLabel{
+				{
+					Old: label.Label{ID: "l1", Name: "news"},
+					New: label.Label{Name: "news", Color: &label.Color{Background: "#000000", Text: "#ffffff"}},
+				},
+			},
+		},
+	}
+
+	b, err := json.Marshal(d)
+	require.Nil(t, err)
+	expected := `{
+  "filters": {
+    "added": [
+      {"criteria": {"from": "a@b.com"}, "actions": {"addLabel": "work", "category": "updates"}}
+    ],
+    "removed": [
+      {"id": "abc", "criteria": {"query": "foo"}, "actions": {"archive": true}}
+    ],
+    "modified": [
+      {
+        "old": {"id": "def", "criteria": {"to": "me"}, "actions": {"star": true}},
+        "new": {"criteria": {"to": "me"}, "actions": {"star": true, "markRead": true}}
+      }
+    ],
+    "ignored": []
+  },
+  "labels": {
+    "added": [{"name": "work"}],
+    "removed": [],
+    "modified": [
+      {
+        "old": {"id": "l1", "name": "news"},
+        "new": {"name": "news", "color": {"background": "#000000", "text": "#ffffff"}}
+      }
+    ],
+    "ignored": [{"id": "l2", "name": "shared"}]
+  }
+}`
+	assert.JSONEq(t, expected, string(b))
+}
+
+func TestEmptyDiffJSON(t *testing.T) {
+	b, err := json.Marshal(ConfigDiff{})
+	require.Nil(t, err)
+	expected := `{
+  "filters": {"added": [], "removed": [], "modified": [], "ignored": []},
+  "labels": {"added": [], "removed": [], "modified": [], "ignored": []}
+}`
+	assert.JSONEq(t, expected, string(b))
+}
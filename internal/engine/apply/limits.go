@@ -0,0 +1,98 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+)
+
+// ErrLimitExceeded is returned when a diff makes more changes than allowed.
+var ErrLimitExceeded = errors.New("change limit exceeded")
+
+const (
+	// Deleting more than this fraction of the upstream filters is
+	// considered a mistake (e.g. a config producing no rules), unless
+	// explicitly forced.
+	maxDeletedFraction = 0.5
+	// Deleting up to this number of filters is always allowed, so that
+	// accounts with few filters are not affected by the fraction check.
+	minMassDeletion = 5
+)
+
+// Limits restrict the changes that Apply is allowed to make.
+type Limits struct {
+	// MaxChanges is the maximum number of filters and labels added,
+	// modified or removed. A negative value means no limit.
+	MaxChanges int
+	// MaxDeletions is the maximum number of filters and labels removed.
+	// A negative value means no limit.
+	MaxDeletions int
+	// AllowMassDeletion disables the check refusing to delete most of the
+	// upstream filters.
+	AllowMassDeletion bool
+}
+
+// NoLimits doesn't restrict the changes in any way.
+var NoLimits = Limits{MaxChanges: -1, MaxDeletions: -1, AllowMassDeletion: true}
+
+// LimitsFromConfig returns the limits set in the config. The ones not
+// specified are disabled, except for the mass deletion check.
+func LimitsFromConfig(s *v1alpha3.ApplySettings) Limits {
+	res := Limits{MaxChanges: -1, MaxDeletions: -1}
+	if s == nil {
+		return res
+	}
+	if s.MaxChanges != nil {
+		res.MaxChanges = *s.MaxChanges
+	}
+	if s.MaxDeletions != nil {
+		res.MaxDeletions = *s.MaxDeletions
+	}
+	return res
+}
+
+// Check returns an error wrapping ErrLimitExceeded if the diff exceeds the
+// limits. Removed labels are only counted if their removal is allowed.
+func (l Limits) Check(d ConfigDiff, allowRemoveLabels bool) error {
+	changes := numChanges(d, allowRemoveLabels)
+	deletions := numDeletions(d, allowRemoveLabels)
+
+	if l.MaxChanges >= 0 && changes > l.MaxChanges {
+		return fmt.Errorf("%w: %d changes, but at most %d are allowed",
+			ErrLimitExceeded, changes, l.MaxChanges)
+	}
+	if l.MaxDeletions >= 0 && deletions > l.MaxDeletions {
+		return fmt.Errorf("%w: %d deletions, but at most %d are allowed",
+			ErrLimitExceeded, deletions, l.MaxDeletions)
+	}
+
+	removed := len(d.FiltersDiff.Removed)
+	upstream := len(d.UpstreamConfig.Filters)
+	if !l.AllowMassDeletion && removed > minMassDeletion &&
+		float64(removed) > maxDeletedFraction*float64(upstream) {
+		return fmt.Errorf("%w: deleting %d out of %d filters",
+			ErrLimitExceeded, removed, upstream)
+	}
+
+	return nil
+}
+
+func numChanges(d ConfigDiff, allowRemoveLabels bool) int {
+	fd, ld := d.FiltersDiff, d.LabelsDiff
+	res := len(fd.Added) + len(fd.Modified) + len(fd.Removed) +
+		len(ld.Added) + len(ld.Modified)
+	if allowRemoveLabels {
+		res += len(ld.Removed)
+	}
+	return res
+}
+
+func numDeletions(d ConfigDiff, allowRemoveLabels bool) int {
+	// Modified filters are replaced, so they are not counted as deletions.
+	res := len(d.FiltersDiff.Removed)
+	if allowRemoveLabels {
+		res += len(d.LabelsDiff.Removed)
+	}
+	return res
+}
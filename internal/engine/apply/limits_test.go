@@ -0,0 +1,119 @@
+package apply
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func someFilters(n int) filter.Filters {
+	var res filter.Filters
+	for i := 0; i < n; i++ {
+		res = append(res, filter.Filter{
+			ID:       fmt.Sprintf("id%d", i),
+			Criteria: filter.Criteria{From: fmt.Sprintf("user%d@example.com", i)},
+			Action:   filter.Actions{Archive: true},
+		})
+	}
+	return res
+}
+
+func TestLimitsCheck(t *testing.T) {
+	upstream := GmailConfig{Filters: someFilters(20)}
+	d := ConfigDiff{
+		FiltersDiff: filter.FiltersDiff{
+			Added:    someFilters(2),
+			Removed:  upstream.Filters[:3],
+			Modified: []filter.ModifiedFilter{{Old: upstream.Filters[3], New: upstream.Filters[4]}},
+		},
+		LabelsDiff: label.LabelsDiff{
+			Removed: label.Labels{{Name: "a"}, {Name: "b"}},
+		},
+		UpstreamConfig: upstream,
+	}
+
+	tests := []struct {
+		name         string
+		limits       Limits
+		removeLabels bool
+		wantErr      bool
+	}{
+		{"no limits", NoLimits, true, false},
+		{"max changes", Limits{MaxChanges: 6, MaxDeletions: -1}, false, false},
+		{"max changes exceeded", Limits{MaxChanges: 6, MaxDeletions: -1}, true, true},
+		{"max deletions", Limits{MaxChanges: -1, MaxDeletions: 3}, false, false},
+		{"max deletions exceeded", Limits{MaxChanges: -1, MaxDeletions: 3}, true, true},
+		{"zero deletions", Limits{MaxChanges: -1, MaxDeletions: 0}, false, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.limits.Check(d, tc.removeLabels)
+			if !tc.wantErr {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrLimitExceeded)
+		})
+	}
+}
+
+func TestLimitsMassDeletion(t *testing.T) {
+	limits := Limits{MaxChanges: -1, MaxDeletions: -1}
+
+	// Deleting all the filters.
+	upstream := GmailConfig{Filters: someFilters(10)}
+	d := ConfigDiff{
+		FiltersDiff:    filter.FiltersDiff{Removed: upstream.Filters},
+		UpstreamConfig: upstream,
+	}
+	assert.ErrorIs(t, limits.Check(d, false), ErrLimitExceeded)
+	limits.AllowMassDeletion = true
+	assert.Nil(t, limits.Check(d, false))
+
+	// Small accounts are not affected.
+	limits.AllowMassDeletion = false
+	upstream = GmailConfig{Filters: someFilters(5)}
+	d = ConfigDiff{
+		FiltersDiff:    filter.FiltersDiff{Removed: upstream.Filters},
+		UpstreamConfig: upstream,
+	}
+	assert.Nil(t, limits.Check(d, false))
+}
+
+func TestLimitsFromConfig(t *testing.T) {
+	assert.Equal(t, Limits{MaxChanges: -1, MaxDeletions: -1}, LimitsFromConfig(nil))
+
+	zero := 0
+	s := &v1alpha3.ApplySettings{MaxDeletions: &zero}
+	assert.Equal(t, Limits{MaxChanges: -1, MaxDeletions: 0}, LimitsFromConfig(s))
+}
+
+type recordingAPI struct {
+	calls int
+}
+
+func (r *recordingAPI) AddLabels(label.Labels) ([]string, error)    { r.calls++; return nil, nil }
+func (r *recordingAPI) AddFilters(filter.Filters) ([]string, error) { r.calls++; return nil, nil }
+func (r *recordingAPI) UpdateLabels(label.Labels) ([]string, error) { r.calls++; return nil, nil }
+func (r *recordingAPI) DeleteFilters([]string) ([]string, error)    { r.calls++; return nil, nil }
+func (r *recordingAPI) DeleteLabels([]string) ([]string, error)     { r.calls++; return nil, nil }
+
+func TestApplyLimitExceeded(t *testing.T) {
+	d := ConfigDiff{
+		FiltersDiff: filter.FiltersDiff{Added: someFilters(3), Removed: someFilters(1)},
+	}
+	api := &recordingAPI{}
+	err := Apply(d, api, false, Limits{MaxChanges: 3, MaxDeletions: -1})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, 0, api.calls)
+
+	err = Apply(d, api, false, Limits{MaxChanges: 4, MaxDeletions: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, api.calls)
+}
@@ -0,0 +1,361 @@
+package apply
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// RenderMarkdown renders the diff in Markdown, e.g. to post it as a comment
+// to a pull request.
+//
+// Filter changes are grouped in collapsible sections, one per label applied,
+// with a table of criteria and actions. Label colors are written in a way
+// that GitHub renders as color swatches.
+func RenderMarkdown(d ConfigDiff) string {
+	return render(d, markdownFormat{})
+}
+
+// RenderHTML renders the diff as a self-contained HTML fragment, with the
+// same structure as RenderMarkdown.
+func RenderHTML(d ConfigDiff) string {
+	return render(d, htmlFormat{})
+}
+
+// reportFormat writes the parts of a report in a specific markup language.
+//
+// All the text passed to it is already escaped with text: both Markdown and
+// HTML output support the tags used inside table cells (e.g. <br> and
+// <del>).
+type reportFormat interface {
+	// text escapes plain text, e.g. criteria and label names.
+	text(s string) string
+	heading(text string) string
+	paragraph(text string) string
+	collapsible(summary, body string) string
+	table(header []string, rows [][]string) string
+	swatch(color string) string
+}
+
+const noLabelSection = "(no label)"
+
+func render(d ConfigDiff, f reportFormat) string {
+	hasFilters := !d.FiltersDiff.Empty() || len(d.FiltersDiff.Ignored) > 0
+	hasLabels := !d.LabelsDiff.Empty() || len(d.LabelsDiff.Ignored) > 0
+	if !hasFilters && !hasLabels {
+		return f.paragraph("No changes.")
+	}
+
+	var b strings.Builder
+	if hasFilters {
+		b.WriteString(f.heading("Filters"))
+		b.WriteString(f.paragraph(d.FiltersDiff.Summary()))
+		for _, s := range filterSections(d.FiltersDiff) {
+			summary := fmt.Sprintf("<b>%s</b>: %s", f.text(s.label), s.diff.Summary())
+			b.WriteString(f.collapsible(summary, f.table(
+				[]string{"Change", "Criteria", "Actions"},
+				filterRows(s.diff, f),
+			)))
+		}
+	}
+	if hasLabels {
+		b.WriteString(f.heading("Labels"))
+		b.WriteString(f.paragraph(d.LabelsDiff.Summary()))
+		b.WriteString(f.table(
+			[]string{"Change", "Label", "Color", "Visibility"},
+			labelRows(d.LabelsDiff, f),
+		))
+	}
+	return b.String()
+}
+
+type filterSection struct {
+	label string
+	diff  filter.FiltersDiff
+}
+
+// filterSections splits the changes by the label applied by the filters.
+// Modified filters are grouped by their new label.
+func filterSections(d filter.FiltersDiff) []filterSection {
+	byLabel := map[string]*filter.FiltersDiff{}
+	get := func(f filter.Filter) *filter.FiltersDiff {
+		l := f.Action.AddLabel
+		if l == "" {
+			l = noLabelSection
+		}
+		res, ok := byLabel[l]
+		if !ok {
+			res = &filter.FiltersDiff{}
+			byLabel[l] = res
+		}
+		return res
+	}
+
+	for _, f := range d.Added {
+		s := get(f)
+		s.Added = append(s.Added, f)
+	}
+	for _, m := range d.Modified {
+		s := get(m.New)
+		s.Modified = append(s.Modified, m)
+	}
+	for _, f := range d.Removed {
+		s := get(f)
+		s.Removed = append(s.Removed, f)
+	}
+	for _, f := range d.Ignored {
+		s := get(f)
+		s.Ignored = append(s.Ignored, f)
+	}
+
+	var res []filterSection
+	for l, fd := range byLabel {
+		res = append(res, filterSection{l, *fd})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		// Filters without labels go last.
+		if (res[i].label == noLabelSection) != (res[j].label == noLabelSection) {
+			return res[j].label == noLabelSection
+		}
+		return res[i].label < res[j].label
+	})
+	return res
+}
+
+func filterRows(d filter.FiltersDiff, f reportFormat) [][]string {
+	var res [][]string
+	for _, fl := range d.Added {
+		res = append(res, []string{
+			"added",
+			joinEscaped(fl.Criteria.Fields(), f),
+			joinEscaped(fl.Action.Fields(), f),
+		})
+	}
+	for _, m := range d.Modified {
+		res = append(res, []string{
+			"modified",
+			diffFields(m.Old.Criteria.Fields(), m.New.Criteria.Fields(), f),
+			diffFields(m.Old.Action.Fields(), m.New.Action.Fields(), f),
+		})
+	}
+	for _, fl := range d.Removed {
+		res = append(res, []string{
+			"removed",
+			joinEscaped(fl.Criteria.Fields(), f),
+			joinEscaped(fl.Action.Fields(), f),
+		})
+	}
+	for _, fl := range d.Ignored {
+		res = append(res, []string{
+			"ignored",
+			joinEscaped(fl.Criteria.Fields(), f),
+			joinEscaped(fl.Action.Fields(), f),
+		})
+	}
+	return res
+}
+
+func labelRows(d label.LabelsDiff, f reportFormat) [][]string {
+	var res [][]string
+	for _, l := range d.Added {
+		res = append(res, []string{"added", f.text(l.Name), colorCell(l.Color, f), visibilityCell(l)})
+	}
+	for _, m := range d.Modified {
+		name := f.text(m.New.Name)
+		if m.Old.Name != m.New.Name {
+			name = fmt.Sprintf("<del>%s</del> %s", f.text(m.Old.Name), name)
+		}
+		color := colorCell(m.New.Color, f)
+		if !sameColor(m.Old.Color, m.New.Color) {
+			color = fmt.Sprintf("%s → %s", colorCell(m.Old.Color, f), color)
+		}
+		// Unspecified visibilities are left as they are.
+		nl := m.New
+		if nl.LabelList == "" {
+			nl.LabelList = m.Old.LabelList
+		}
+		if nl.MessageList == "" {
+			nl.MessageList = m.Old.MessageList
+		}
+		visibility := visibilityCell(nl)
+		if old := visibilityCell(m.Old); old != visibility {
+			visibility = fmt.Sprintf("%s → %s", old, visibility)
+		}
+		res = append(res, []string{"modified", name, color, visibility})
+	}
+	for _, l := range d.Removed {
+		res = append(res, []string{"removed", f.text(l.Name), colorCell(l.Color, f), visibilityCell(l)})
+	}
+	for _, l := range d.Ignored {
+		res = append(res, []string{"ignored", f.text(l.Name), colorCell(l.Color, f), visibilityCell(l)})
+	}
+	return res
+}
+
+func colorCell(c *label.Color, f reportFormat) string {
+	if c == nil {
+		return "none"
+	}
+	return fmt.Sprintf("background %s, text %s", f.swatch(c.Background), f.swatch(c.Text))
+}
+
+// visibilityCell lists the visibilities of the label different from the
+// default.
+func visibilityCell(l label.Label) string {
+	var res []string
+	if l.LabelList != "" && l.LabelList != label.Show {
+		res = append(res, fmt.Sprintf("label list: %s", l.LabelList))
+	}
+	if l.MessageList != "" && l.MessageList != label.Show {
+		res = append(res, fmt.Sprintf("message list: %s", l.MessageList))
+	}
+	if len(res) == 0 {
+		return "default"
+	}
+	return strings.Join(res, ", ")
+}
+
+func sameColor(c1, c2 *label.Color) bool {
+	if c1 == nil || c2 == nil {
+		return c1 == c2
+	}
+	return *c1 == *c2
+}
+
+func joinEscaped(items []string, f reportFormat) string {
+	res := make([]string, len(items))
+	for i, it := range items {
+		res[i] = f.text(it)
+	}
+	return strings.Join(res, "<br>")
+}
+
+// diffFields lists the fields of both versions, marking the ones removed and
+// added.
+func diffFields(old, new []string, f reportFormat) string {
+	var res []string
+	m := difflib.NewMatcher(old, new)
+	for _, op := range m.GetOpCodes() {
+		if op.Tag == 'e' {
+			for _, s := range old[op.I1:op.I2] {
+				res = append(res, f.text(s))
+			}
+			continue
+		}
+		for _, s := range old[op.I1:op.I2] {
+			res = append(res, fmt.Sprintf("<del>%s</del>", f.text(s)))
+		}
+		for _, s := range new[op.J1:op.J2] {
+			res = append(res, fmt.Sprintf("<ins>%s</ins>", f.text(s)))
+		}
+	}
+	return strings.Join(res, "<br>")
+}
+
+type markdownFormat struct{}
+
+// markdownEscaper replaces the characters with a meaning in Markdown (or in
+// its tables) with the equivalent HTML entities.
+var markdownEscaper = strings.NewReplacer(
+	"\\", "&#92;",
+	"`", "&#96;",
+	"*", "&#42;",
+	"_", "&#95;",
+	"~", "&#126;",
+	"[", "&#91;",
+	"]", "&#93;",
+	"|", "&#124;",
+)
+
+func (markdownFormat) text(s string) string {
+	return markdownEscaper.Replace(html.EscapeString(s))
+}
+
+func (markdownFormat) heading(text string) string {
+	return fmt.Sprintf("### %s\n\n", text)
+}
+
+func (markdownFormat) paragraph(text string) string {
+	return text + "\n\n"
+}
+
+func (markdownFormat) collapsible(summary, body string) string {
+	// The empty line after the summary is required to render the Markdown
+	// inside the section.
+	return fmt.Sprintf("<details>\n<summary>%s</summary>\n\n%s</details>\n\n", summary, body)
+}
+
+func (markdownFormat) table(header []string, rows [][]string) string {
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, c := range cells {
+			fmt.Fprintf(&b, " %s |", c)
+		}
+		b.WriteString("\n")
+	}
+	writeRow(header)
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	writeRow(sep)
+	for _, r := range rows {
+		writeRow(r)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (markdownFormat) swatch(color string) string {
+	// GitHub shows a swatch next to colors in code spans.
+	return fmt.Sprintf("`%s`", strings.ReplaceAll(color, "`", ""))
+}
+
+type htmlFormat struct{}
+
+func (htmlFormat) text(s string) string {
+	return html.EscapeString(s)
+}
+
+func (htmlFormat) heading(text string) string {
+	return fmt.Sprintf("<h3>%s</h3>\n", text)
+}
+
+func (htmlFormat) paragraph(text string) string {
+	return fmt.Sprintf("<p>%s</p>\n", text)
+}
+
+func (htmlFormat) collapsible(summary, body string) string {
+	return fmt.Sprintf("<details>\n<summary>%s</summary>\n%s</details>\n", summary, body)
+}
+
+func (htmlFormat) table(header []string, rows [][]string) string {
+	var b strings.Builder
+	b.WriteString("<table>\n<tr>")
+	for _, h := range header {
+		fmt.Fprintf(&b, "<th>%s</th>", h)
+	}
+	b.WriteString("</tr>\n")
+	for _, r := range rows {
+		b.WriteString("<tr>")
+		for _, c := range r {
+			fmt.Fprintf(&b, `<td style="vertical-align:top">%s</td>`, c)
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+func (htmlFormat) swatch(color string) string {
+	c := html.EscapeString(color)
+	return fmt.Sprintf(`<span style="display:inline-block;width:0.9em;height:0.9em;`+
+		`border:1px solid #888;vertical-align:middle;background-color:%s"></span> <code>%s</code>`, c, c)
+}
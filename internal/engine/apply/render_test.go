@@ -0,0 +1,124 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func renderTestDiff() ConfigDiff {
+	return ConfigDiff{
+		FiltersDiff: filter.FiltersDiff{
+			Added: filter.Filters{
+				{
+					Criteria: filter.Criteria{From: "a@b.com"},
+					Action:   filter.Actions{AddLabel: "work"},
+				},
+				{
+					Criteria: filter.Criteria{Query: "a|b <c>"},
+					Action:   filter.Actions{Archive: true},
+				},
+			},
+			Modified: []filter.ModifiedFilter{
+				{
+					Old: filter.Filter{
+						Criteria: filter.Criteria{To: "me"},
+						Action:   filter.Actions{AddLabel: "news", Star: true},
+					},
+					New: filter.Filter{
+						Criteria: filter.Criteria{To: "me"},
+						Action:   filter.Actions{AddLabel: "news", MarkRead: true},
+					},
+				},
+			},
+		},
+		LabelsDiff: label.LabelsDiff{
+			Added: label.Labels{{Name: "work"}},
+			Modified: []label.ModifiedLabel{
+				{
+					Old: label.Label{Name: "news"},
+					New: label.Label{Name: "news", Color: &label.Color{Background: "#000000", Text: "#ffffff"}},
+				},
+			},
+		},
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	expected := "### Filters\n\n" +
+		"2 added, 1 modified, 0 removed\n\n" +
+		"<details>\n<summary><b>news</b>: 0 added, 1 modified, 0 removed</summary>\n\n" +
+		"| Change | Criteria | Actions |\n" +
+		"| --- | --- | --- |\n" +
+		"| modified | to: me | <del>star</del><br><ins>mark as read</ins><br>apply label: news |\n\n" +
+		"</details>\n\n" +
+		"<details>\n<summary><b>work</b>: 1 added, 0 modified, 0 removed</summary>\n\n" +
+		"| Change | Criteria | Actions |\n" +
+		"| --- | --- | --- |\n" +
+		"| added | from: a@b.com | apply label: work |\n\n" +
+		"</details>\n\n" +
+		"<details>\n<summary><b>(no label)</b>: 1 added, 0 modified, 0 removed</summary>\n\n" +
+		"| Change | Criteria | Actions |\n" +
+		"| --- | --- | --- |\n" +
+		"| added | query: a&#124;b &lt;c&gt; | archive |\n\n" +
+		"</details>\n\n" +
+		"### Labels\n\n" +
+		"1 added, 1 modified, 0 removed\n\n" +
+		"| Change | Label | Color | Visibility |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| added | work | none | default |\n" +
+		"| modified | news | none → background `#000000`, text `#ffffff` | default |\n\n"
+	assert.Equal(t, expected, RenderMarkdown(renderTestDiff()))
+}
+
+func TestRenderMarkdownEscaping(t *testing.T) {
+	d := ConfigDiff{
+		FiltersDiff: filter.FiltersDiff{
+			Removed: filter.Filters{
+				{
+					Criteria: filter.Criteria{Query: "a|b *c* `d` _e_"},
+					Action:   filter.Actions{AddLabel: "x_y*"},
+				},
+			},
+		},
+	}
+	expected := "### Filters\n\n" +
+		"0 added, 0 modified, 1 removed\n\n" +
+		"<details>\n<summary><b>x&#95;y&#42;</b>: 0 added, 0 modified, 1 removed</summary>\n\n" +
+		"| Change | Criteria | Actions |\n" +
+		"| --- | --- | --- |\n" +
+		"| removed | query: a&#124;b &#42;c&#42; &#96;d&#96; &#95;e&#95; | apply label: x&#95;y&#42; |\n\n" +
+		"</details>\n\n"
+	assert.Equal(t, expected, RenderMarkdown(d))
+}
+
+func TestRenderHTML(t *testing.T) {
+	d := ConfigDiff{
+		LabelsDiff: label.LabelsDiff{
+			Removed: label.Labels{{Name: "a&b", Color: &label.Color{Background: "#000000", Text: "#ffffff"}}},
+		},
+	}
+	expected := "<h3>Labels</h3>\n" +
+		"<p>0 added, 0 modified, 1 removed</p>\n" +
+		"<table>\n<tr><th>Change</th><th>Label</th><th>Color</th><th>Visibility</th></tr>\n" +
+		`<tr><td style="vertical-align:top">removed</td>` +
+		`<td style="vertical-align:top">a&amp;b</td>` +
+		`<td style="vertical-align:top">background ` +
+		`<span style="display:inline-block;width:0.9em;height:0.9em;border:1px solid #888;vertical-align:middle;background-color:#000000"></span> <code>#000000</code>, ` +
+		`text <span style="display:inline-block;width:0.9em;height:0.9em;border:1px solid #888;vertical-align:middle;background-color:#ffffff"></span> <code>#ffffff</code></td><td style="vertical-align:top">default</td></tr>` +
+		"\n</table>\n"
+	assert.Equal(t, expected, RenderHTML(d))
+
+	// Filters are in collapsible sections too.
+	out := RenderHTML(renderTestDiff())
+	assert.Contains(t, out, "<details>\n<summary><b>work</b>: 1 added, 0 modified, 0 removed</summary>\n<table>")
+	assert.Contains(t, out, "query: a|b &lt;c&gt;")
+}
+
+func TestRenderEmpty(t *testing.T) {
+	assert.Equal(t, "No changes.\n\n", RenderMarkdown(ConfigDiff{}))
+	assert.Equal(t, "<p>No changes.</p>\n", RenderHTML(ConfigDiff{}))
+}
@@ -0,0 +1,124 @@
+package apply
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+// Scope selects a part of the config, to apply only the changes in it.
+type Scope struct {
+	labelPatterns []string
+	tagged        map[filter.Filter]bool
+	// Labels used by the filters of tagged rules.
+	taggedLabels map[string]bool
+}
+
+// NewScope creates a scope including the labels matching the given patterns
+// and the rules with at least one of the given tags.
+//
+// See label.MatchPattern for the syntax of label patterns.
+func NewScope(labelPatterns, tags []string, rules []parser.Rule) (Scope, error) {
+	for _, p := range labelPatterns {
+		if err := label.ValidatePattern(p); err != nil {
+			return Scope{}, err
+		}
+	}
+
+	res := Scope{
+		labelPatterns: labelPatterns,
+		tagged:        map[filter.Filter]bool{},
+		taggedLabels:  map[string]bool{},
+	}
+	found := false
+	for i, r := range rules {
+		if !hasAnyTag(r, tags) {
+			continue
+		}
+		found = true
+		fs, err := filter.FromRule(r, filter.DefaultSizeLimit)
+		if err != nil {
+			return Scope{}, fmt.Errorf("generating %s: %w", parser.RuleName(i, r.Source), err)
+		}
+		for _, f := range fs {
+			res.tagged[f] = true
+			if f.Action.AddLabel != "" {
+				res.taggedLabels[f.Action.AddLabel] = true
+			}
+		}
+	}
+	if len(tags) > 0 && !found {
+		return Scope{}, fmt.Errorf("no rules with tags %s", strings.Join(tags, ", "))
+	}
+	return res, nil
+}
+
+// Restrict returns a diff with only the changes in the scope. Everything
+// else is left untouched upstream.
+//
+// Filters are in the scope if they apply one of the selected labels or, for
+// added and modified filters, if they are generated by a tagged rule. Labels
+// are in the scope if they match one of the patterns, or if they are used by
+// a tagged rule. Removed filters don't come from a rule anymore, so they are
+// only in the scope when they apply a selected label.
+func (d ConfigDiff) Restrict(s Scope) (ConfigDiff, error) {
+	return d.Select(s.contains)
+}
+
+// contains returns whether the single change is in the scope.
+func (s Scope) contains(change ConfigDiff) bool {
+	fd, ld := change.FiltersDiff, change.LabelsDiff
+	switch {
+	case len(fd.Added) > 0:
+		return s.containsFilter(fd.Added[0])
+	case len(fd.Modified) > 0:
+		m := fd.Modified[0]
+		return s.containsFilter(m.New) || s.matchesLabel(m.Old.Action.AddLabel)
+	case len(fd.Removed) > 0:
+		return s.matchesLabel(fd.Removed[0].Action.AddLabel)
+	case len(ld.Added) > 0:
+		return s.containsLabel(ld.Added[0].Name)
+	case len(ld.Modified) > 0:
+		m := ld.Modified[0]
+		return s.containsLabel(m.Old.Name) || s.containsLabel(m.New.Name)
+	case len(ld.Removed) > 0:
+		return s.matchesLabel(ld.Removed[0].Name)
+	}
+	return false
+}
+
+func (s Scope) containsFilter(f filter.Filter) bool {
+	key := f
+	key.ID = ""
+	return s.tagged[key] || s.matchesLabel(f.Action.AddLabel)
+}
+
+func (s Scope) containsLabel(name string) bool {
+	return s.taggedLabels[name] || s.matchesLabel(name)
+}
+
+func (s Scope) matchesLabel(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, p := range s.labelPatterns {
+		if label.MatchPattern(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyTag(r parser.Rule, tags []string) bool {
+	for _, t := range tags {
+		for _, rt := range r.Tags {
+			if t == rt {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+func TestMatchLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Work", true},
+		{"Work/a", true},
+		{"Workshop", false},
+		{"Home/News", true},
+		{"News", true},
+		{"Home", false},
+		// Filters without a label never match.
+		{"", false},
+	}
+	s, err := NewScope([]string{"Work/**", "**/News"}, nil, nil)
+	assert.Nil(t, err)
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, s.matchesLabel(tc.name), tc.name)
+	}
+}
+
+func TestNewScopeInvalidPattern(t *testing.T) {
+	_, err := NewScope([]string{"[a"}, nil, nil)
+	assert.NotNil(t, err)
+}
+
+func scopeTestConfig(t *testing.T) (ConfigParseRes, GmailConfig) {
+	t.Helper()
+	cfg := v1alpha3.Config{
+		Version: v1alpha3.Version,
+		Labels:  []v1alpha3.Label{{Name: "Work"}, {Name: "Work/Boss"}, {Name: "News"}},
+		Rules: []v1alpha3.Rule{
+			{
+				Filter:  v1alpha3.FilterNode{From: "boss@work.com"},
+				Actions: v1alpha3.Actions{Labels: []string{"Work/Boss"}},
+			},
+			{
+				Filter:  v1alpha3.FilterNode{From: "news@example.com"},
+				Actions: v1alpha3.Actions{Labels: []string{"News"}},
+				Tags:    []string{"newsletters"},
+			},
+			{
+				Filter:  v1alpha3.FilterNode{From: "spam@example.com"},
+				Actions: v1alpha3.Actions{Delete: true},
+			},
+		},
+	}
+	local, err := FromConfig(cfg)
+	require.Nil(t, err)
+	upstream := GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "Work"}, {ID: "l2", Name: "Old"}},
+	}
+	return local, upstream
+}
+
+func TestRestrictByLabel(t *testing.T) {
+	local, upstream := scopeTestConfig(t)
+	d, err := Diff(local.GmailConfig, upstream, false, DefaultContextLines, false)
+	require.Nil(t, err)
+
+	s, err := NewScope([]string{"Work/**"}, nil, local.Rules)
+	require.Nil(t, err)
+	res, err := d.Restrict(s)
+	require.Nil(t, err)
+	require.Nil(t, res.Validate())
+
+	require.Len(t, res.FiltersDiff.Added, 1)
+	assert.Equal(t, "Work/Boss", res.FiltersDiff.Added[0].Action.AddLabel)
+	assert.Equal(t, label.Labels{{Name: "Work/Boss"}}, res.LabelsDiff.Added)
+	assert.Empty(t, res.LabelsDiff.Removed)
+}
+
+func TestRestrictByTag(t *testing.T) {
+	local, upstream := scopeTestConfig(t)
+	d, err := Diff(local.GmailConfig, upstream, false, DefaultContextLines, false)
+	require.Nil(t, err)
+
+	s, err := NewScope(nil, []string{"newsletters"}, local.Rules)
+	require.Nil(t, err)
+	res, err := d.Restrict(s)
+	require.Nil(t, err)
+	require.Nil(t, res.Validate())
+
+	require.Len(t, res.FiltersDiff.Added, 1)
+	assert.Equal(t, "news@example.com", res.FiltersDiff.Added[0].Criteria.From)
+	// The label used by the tagged rule is created as well.
+	assert.Equal(t, label.Labels{{Name: "News"}}, res.LabelsDiff.Added)
+}
+
+func TestNewScopeErrors(t *testing.T) {
+	_, err := NewScope([]string{"[a"}, nil, nil)
+	assert.NotNil(t, err)
+	_, err = NewScope(nil, []string{"missing"}, []parser.Rule{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,140 @@
+package apply
+
+import (
+	"fmt"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// Select returns a diff containing only the changes accepted by keep.
+//
+// keep is called once for every change, with a diff containing only that
+// change, so that it can be printed. Filters are visited before labels, and
+// for each of them added, modified and removed changes in this order.
+//
+// The local config of the result is the upstream config with only the
+// selected changes applied, so that the result can be validated as usual.
+func (d ConfigDiff) Select(keep func(ConfigDiff) bool) (ConfigDiff, error) {
+	res := d
+	res.FiltersDiff.Added, res.FiltersDiff.Modified, res.FiltersDiff.Removed = nil, nil, nil
+	res.LabelsDiff.Added, res.LabelsDiff.Modified, res.LabelsDiff.Removed = nil, nil, nil
+
+	single := func(fd filter.FiltersDiff, ld label.LabelsDiff) ConfigDiff {
+		r := res
+		r.FiltersDiff.Added, r.FiltersDiff.Modified, r.FiltersDiff.Removed = fd.Added, fd.Modified, fd.Removed
+		r.LabelsDiff.Added, r.LabelsDiff.Modified, r.LabelsDiff.Removed = ld.Added, ld.Modified, ld.Removed
+		// Ignored filters and labels are not changes.
+		r.FiltersDiff.Ignored, r.LabelsDiff.Ignored = nil, nil
+		return r
+	}
+
+	for _, f := range d.FiltersDiff.Added {
+		if keep(single(filter.FiltersDiff{Added: filter.Filters{f}}, label.LabelsDiff{})) {
+			res.FiltersDiff.Added = append(res.FiltersDiff.Added, f)
+		}
+	}
+	for _, m := range d.FiltersDiff.Modified {
+		if keep(single(filter.FiltersDiff{Modified: []filter.ModifiedFilter{m}}, label.LabelsDiff{})) {
+			res.FiltersDiff.Modified = append(res.FiltersDiff.Modified, m)
+		}
+	}
+	for _, f := range d.FiltersDiff.Removed {
+		if keep(single(filter.FiltersDiff{Removed: filter.Filters{f}}, label.LabelsDiff{})) {
+			res.FiltersDiff.Removed = append(res.FiltersDiff.Removed, f)
+		}
+	}
+	for _, l := range d.LabelsDiff.Added {
+		if keep(single(filter.FiltersDiff{}, label.LabelsDiff{Added: label.Labels{l}})) {
+			res.LabelsDiff.Added = append(res.LabelsDiff.Added, l)
+		}
+	}
+	for _, m := range d.LabelsDiff.Modified {
+		if keep(single(filter.FiltersDiff{}, label.LabelsDiff{Modified: []label.ModifiedLabel{m}})) {
+			res.LabelsDiff.Modified = append(res.LabelsDiff.Modified, m)
+		}
+	}
+	for _, l := range d.LabelsDiff.Removed {
+		if keep(single(filter.FiltersDiff{}, label.LabelsDiff{Removed: label.Labels{l}})) {
+			res.LabelsDiff.Removed = append(res.LabelsDiff.Removed, l)
+		}
+	}
+
+	res.LocalConfig = selectedConfig(d.UpstreamConfig, res)
+	res.LocalConfig.Unmanaged = d.LocalConfig.Unmanaged
+	return res, checkSkippedLabels(d.LabelsDiff, res)
+}
+
+// selectedConfig returns the upstream config with the changes in the diff
+// applied.
+func selectedConfig(upstream GmailConfig, d ConfigDiff) GmailConfig {
+	removedFilters := map[string]bool{}
+	for _, f := range d.FiltersDiff.AllRemoved() {
+		removedFilters[f.ID] = true
+	}
+	changedLabels := map[string]*label.Label{}
+	renames := map[string]string{}
+	for _, l := range d.LabelsDiff.Removed {
+		changedLabels[l.Name] = nil
+	}
+	for _, m := range d.LabelsDiff.Modified {
+		changedLabels[m.Old.Name] = &m.New
+		if m.Renamed() {
+			renames[m.Old.Name] = m.New.Name
+		}
+	}
+
+	var res GmailConfig
+	for _, f := range renameFilterLabels(upstream.Filters, renames) {
+		if !removedFilters[f.ID] {
+			res.Filters = append(res.Filters, f)
+		}
+	}
+	res.Filters = append(res.Filters, d.FiltersDiff.AllAdded()...)
+
+	for _, l := range upstream.Labels {
+		nl, changed := changedLabels[l.Name]
+		switch {
+		case !changed:
+			res.Labels = append(res.Labels, l)
+		case nl != nil:
+			res.Labels = append(res.Labels, *nl)
+		}
+	}
+	res.Labels = append(res.Labels, d.LabelsDiff.Added...)
+
+	return res
+}
+
+// checkSkippedLabels makes sure that the selected filters don't need the
+// labels whose creation or rename was skipped.
+func checkSkippedLabels(orig label.LabelsDiff, d ConfigDiff) error {
+	selected := map[string]bool{}
+	for _, l := range d.LabelsDiff.Added {
+		selected[l.Name] = true
+	}
+	for _, m := range d.LabelsDiff.Modified {
+		selected[m.New.Name] = true
+	}
+
+	var skipped []string
+	for _, l := range orig.Added {
+		skipped = append(skipped, l.Name)
+	}
+	for _, m := range orig.Modified {
+		if m.Renamed() {
+			skipped = append(skipped, m.New.Name)
+		}
+	}
+	for _, name := range skipped {
+		if selected[name] {
+			continue
+		}
+		for _, f := range d.FiltersDiff.AllAdded() {
+			if f.HasLabel(name) {
+				return fmt.Errorf("a selected filter uses label %q, whose creation was skipped", name)
+			}
+		}
+	}
+	return nil
+}
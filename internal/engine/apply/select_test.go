@@ -0,0 +1,88 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func selectTestDiff(t *testing.T) ConfigDiff {
+	t.Helper()
+	upstream := GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "old"}, {ID: "l2", Name: "news"}},
+		Filters: filter.Filters{
+			{ID: "f1", Criteria: filter.Criteria{From: "a@b.com"}, Action: filter.Actions{AddLabel: "old"}},
+			{ID: "f2", Criteria: filter.Criteria{From: "news@b.com"}, Action: filter.Actions{AddLabel: "news"}},
+		},
+	}
+	local := GmailConfig{
+		Labels: label.Labels{{Name: "news"}, {Name: "work"}},
+		Filters: filter.Filters{
+			{Criteria: filter.Criteria{From: "news@b.com"}, Action: filter.Actions{AddLabel: "news"}},
+			{Criteria: filter.Criteria{From: "boss@b.com"}, Action: filter.Actions{AddLabel: "work"}},
+		},
+	}
+	d, err := Diff(local, upstream, false, DefaultContextLines, false)
+	require.Nil(t, err)
+	require.Nil(t, d.Validate())
+	return d
+}
+
+func TestSelectAll(t *testing.T) {
+	d := selectTestDiff(t)
+	var changes []string
+	res, err := d.Select(func(c ConfigDiff) bool {
+		changes = append(changes, c.Summary())
+		return true
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []string{
+		"filters: 1 added, 0 modified, 0 removed",
+		"filters: 0 added, 0 modified, 1 removed",
+		"labels: 1 added, 0 modified, 0 removed",
+		"labels: 0 added, 0 modified, 1 removed",
+	}, changes)
+	assert.Equal(t, d.FiltersDiff.Added, res.FiltersDiff.Added)
+	assert.Equal(t, d.LabelsDiff.Removed, res.LabelsDiff.Removed)
+	assert.Nil(t, res.Validate())
+	// The resulting config is the same as the local one.
+	assert.ElementsMatch(t, []string{"news", "work"}, labelNames(res.LocalConfig.Labels))
+	assert.Len(t, res.LocalConfig.Filters, 2)
+}
+
+func TestSelectNone(t *testing.T) {
+	d := selectTestDiff(t)
+	res, err := d.Select(func(ConfigDiff) bool { return false })
+	require.Nil(t, err)
+	assert.True(t, res.Empty())
+	assert.Equal(t, d.UpstreamConfig.Filters, res.LocalConfig.Filters)
+}
+
+func TestSelectInvalid(t *testing.T) {
+	d := selectTestDiff(t)
+
+	// Removing the label, but keeping the filter using it.
+	res, err := d.Select(func(c ConfigDiff) bool {
+		return len(c.LabelsDiff.Removed) > 0
+	})
+	require.Nil(t, err)
+	assert.NotNil(t, res.Validate())
+
+	// Adding the filter, but not its label.
+	_, err = d.Select(func(c ConfigDiff) bool {
+		return len(c.FiltersDiff.Added) > 0
+	})
+	assert.NotNil(t, err)
+}
+
+func labelNames(ls label.Labels) []string {
+	var res []string
+	for _, l := range ls {
+		res = append(res, l.Name)
+	}
+	return res
+}
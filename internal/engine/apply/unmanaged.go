@@ -0,0 +1,99 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// Unmanaged matches the filters and labels in Gmail that are not managed by
+// the config. The zero value matches nothing.
+type Unmanaged struct {
+	filters []unmanagedFilter
+	labels  []string
+}
+
+type unmanagedFilter struct {
+	label    string
+	criteria *regexp.Regexp
+	forward  *regexp.Regexp
+}
+
+// UnmanagedFromConfig parses the unmanaged section of the config.
+//
+// Label patterns follow the syntax of label.MatchPattern. In criteria and
+// forward patterns, '*' matches any sequence of characters and the case is
+// ignored.
+func UnmanagedFromConfig(u *v1alpha3.Unmanaged) (Unmanaged, error) {
+	if u == nil {
+		return Unmanaged{}, nil
+	}
+	res := Unmanaged{labels: u.Labels}
+	for _, p := range u.Labels {
+		if err := label.ValidatePattern(p); err != nil {
+			return res, err
+		}
+	}
+	for i, f := range u.Filters {
+		if f == (v1alpha3.UnmanagedFilter{}) {
+			return res, fmt.Errorf("unmanaged filter #%d: %w", i, errors.New("no properties to match"))
+		}
+		if f.Label != "" {
+			if err := label.ValidatePattern(f.Label); err != nil {
+				return res, fmt.Errorf("unmanaged filter #%d: %w", i, err)
+			}
+		}
+		res.filters = append(res.filters, unmanagedFilter{
+			label:    f.Label,
+			criteria: wildcardRegexp(f.Criteria),
+			forward:  wildcardRegexp(f.Forward),
+		})
+	}
+	return res, nil
+}
+
+// MatchFilter returns whether the filter is not managed by the config.
+func (u Unmanaged) MatchFilter(f filter.Filter) bool {
+	for _, uf := range u.filters {
+		if uf.label != "" && !label.MatchPattern(uf.label, f.Action.AddLabel) {
+			continue
+		}
+		if uf.criteria != nil && !uf.criteria.MatchString(f.Criteria.ToGmailSearch()) {
+			continue
+		}
+		if uf.forward != nil && !uf.forward.MatchString(f.Action.Forward) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// MatchLabel returns whether the label is not managed by the config.
+func (u Unmanaged) MatchLabel(l label.Label) bool {
+	for _, p := range u.labels {
+		if label.MatchPattern(p, l.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardRegexp returns a regexp matching the whole string with the
+// pattern, where '*' matches any sequence of characters. An empty pattern
+// returns nil.
+func wildcardRegexp(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("(?is)^" + strings.Join(parts, ".*") + "$")
+}
@@ -0,0 +1,109 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func TestUnmanagedMatch(t *testing.T) {
+	u, err := UnmanagedFromConfig(&v1alpha3.Unmanaged{
+		Filters: []v1alpha3.UnmanagedFilter{
+			{Label: "Shared/**"},
+			{Criteria: "from:*@partner.com*", Label: "Partner"},
+			{Forward: "*@backup.example.com"},
+		},
+		Labels: []string{"Shared/**"},
+	})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name string
+		f    filter.Filter
+		want bool
+	}{
+		{
+			"label",
+			filter.Filter{Action: filter.Actions{AddLabel: "Shared/team"}},
+			true,
+		},
+		{
+			"criteria and label",
+			filter.Filter{
+				Criteria: filter.Criteria{From: "Bob@Partner.com"},
+				Action:   filter.Actions{AddLabel: "Partner"},
+			},
+			true,
+		},
+		{
+			"criteria only",
+			filter.Filter{
+				Criteria: filter.Criteria{From: "bob@partner.com"},
+				Action:   filter.Actions{AddLabel: "Other"},
+			},
+			false,
+		},
+		{
+			"forward",
+			filter.Filter{Action: filter.Actions{Forward: "me@backup.example.com"}},
+			true,
+		},
+		{
+			"no match",
+			filter.Filter{Action: filter.Actions{AddLabel: "Work"}},
+			false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, u.MatchFilter(tc.f))
+		})
+	}
+
+	assert.True(t, u.MatchLabel(label.Label{Name: "Shared"}))
+	assert.False(t, u.MatchLabel(label.Label{Name: "Work"}))
+	assert.False(t, Unmanaged{}.MatchFilter(filter.Filter{}))
+}
+
+func TestUnmanagedInvalid(t *testing.T) {
+	_, err := UnmanagedFromConfig(&v1alpha3.Unmanaged{
+		Filters: []v1alpha3.UnmanagedFilter{{}},
+	})
+	assert.NotNil(t, err)
+	_, err = UnmanagedFromConfig(&v1alpha3.Unmanaged{Labels: []string{"[a"}})
+	assert.NotNil(t, err)
+}
+
+func TestDiffUnmanaged(t *testing.T) {
+	u, err := UnmanagedFromConfig(&v1alpha3.Unmanaged{
+		Filters: []v1alpha3.UnmanagedFilter{{Criteria: "*@partner.com*"}},
+	})
+	require.Nil(t, err)
+	upstream := GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "partner"}, {ID: "l2", Name: "work"}},
+		Filters: filter.Filters{
+			{
+				ID:       "f1",
+				Criteria: filter.Criteria{From: "bob@partner.com"},
+				Action:   filter.Actions{AddLabel: "partner"},
+			},
+		},
+	}
+	local := GmailConfig{
+		Labels:    label.Labels{{Name: "work"}},
+		Unmanaged: u,
+	}
+
+	d, err := Diff(local, upstream, false, DefaultContextLines, false)
+	require.Nil(t, err)
+	assert.True(t, d.Empty())
+	assert.Equal(t, upstream.Filters, d.FiltersDiff.Ignored)
+	// The label is used by the unmanaged filter, so it's kept too.
+	assert.Equal(t, label.Labels{upstream.Labels[0]}, d.LabelsDiff.Ignored)
+	assert.Contains(t, d.String(), "ignored filter")
+}
@@ -0,0 +1,52 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// ErrNonEmptyLabels is returned when labels to be removed still have
+// messages.
+var ErrNonEmptyLabels = errors.New("labels to remove are not empty")
+
+// UsageAPI provides the usage of Gmail labels.
+type UsageAPI interface {
+	LabelUsage(id string) (label.Usage, error)
+}
+
+// RemovedLabel is a label removed by a diff, with the messages having it.
+type RemovedLabel struct {
+	Label label.Label
+	Usage label.Usage
+}
+
+// RemovedLabels returns the labels removed by the diff, with their usage.
+func RemovedLabels(d ConfigDiff, api UsageAPI) ([]RemovedLabel, error) {
+	var res []RemovedLabel
+	for _, l := range d.LabelsDiff.Removed {
+		u, err := api.LabelUsage(l.ID)
+		if err != nil {
+			return nil, fmt.Errorf("getting usage of label %q: %w", l.Name, err)
+		}
+		res = append(res, RemovedLabel{Label: l, Usage: u})
+	}
+	return res, nil
+}
+
+// CheckEmpty returns ErrNonEmptyLabels if some of the removed labels still
+// have messages, as removing them would remove the label from the messages.
+func CheckEmpty(removed []RemovedLabel) error {
+	var nonEmpty []string
+	for _, r := range removed {
+		if !r.Usage.Empty() {
+			nonEmpty = append(nonEmpty, fmt.Sprintf("%q (%s)", r.Label.Name, r.Usage))
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNonEmptyLabels, strings.Join(nonEmpty, ", "))
+}
@@ -0,0 +1,45 @@
+package apply
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+type usageAPI map[string]label.Usage
+
+func (a usageAPI) LabelUsage(id string) (label.Usage, error) {
+	u, ok := a[id]
+	if !ok {
+		return label.Usage{}, errors.New("not found")
+	}
+	return u, nil
+}
+
+func TestRemovedLabels(t *testing.T) {
+	d := ConfigDiff{
+		LabelsDiff: label.LabelsDiff{
+			Removed: label.Labels{{ID: "1", Name: "empty"}, {ID: "2", Name: "full"}},
+		},
+	}
+	api := usageAPI{"1": {}, "2": {Messages: 12, Threads: 10}}
+
+	removed, err := RemovedLabels(d, api)
+	require.Nil(t, err)
+	assert.Equal(t, []RemovedLabel{
+		{Label: d.LabelsDiff.Removed[0]},
+		{Label: d.LabelsDiff.Removed[1], Usage: label.Usage{Messages: 12, Threads: 10}},
+	}, removed)
+
+	err = CheckEmpty(removed)
+	assert.ErrorIs(t, err, ErrNonEmptyLabels)
+	assert.ErrorContains(t, err, `"full" (12 messages in 10 threads)`)
+	assert.Nil(t, CheckEmpty(removed[:1]))
+
+	_, err = RemovedLabels(d, usageAPI{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,180 @@
+// Package backup saves snapshots of the Gmail settings, so that they can be
+// restored later.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+const (
+	// DefaultKeep is the number of snapshots kept by default.
+	DefaultKeep = 20
+
+	snapshotExt        = ".json"
+	snapshotTimeFormat = "20060102-150405"
+)
+
+// Retention decides which snapshots to keep.
+type Retention struct {
+	// Keep is the maximum number of snapshots to keep. Zero disables the
+	// backups.
+	Keep int
+	// MaxAge is the maximum age of the snapshots. Zero means no limit.
+	MaxAge time.Duration
+}
+
+// RetentionFromConfig returns the retention set in the config, or the
+// default one.
+func RetentionFromConfig(s *v1alpha3.ApplySettings) Retention {
+	res := Retention{Keep: DefaultKeep}
+	if s == nil || s.Backups == nil {
+		return res
+	}
+	if s.Backups.Keep != nil {
+		res.Keep = *s.Backups.Keep
+	}
+	res.MaxAge = time.Duration(s.Backups.MaxAgeDays) * 24 * time.Hour
+	return res
+}
+
+// Enabled returns whether backups have to be saved.
+func (r Retention) Enabled() bool {
+	return r.Keep > 0
+}
+
+// Snapshot is a saved copy of the Gmail settings.
+type Snapshot struct {
+	// Name identifies the snapshot in the backups directory.
+	Name string
+	Path string
+	Time time.Time
+}
+
+type snapshotFile struct {
+	Time    time.Time      `json:"time"`
+	Labels  label.Labels   `json:"labels"`
+	Filters filter.Filters `json:"filters"`
+}
+
+// Save writes a new snapshot of the given config in the directory, which is
+// created if needed.
+func Save(dir string, cfg apply.GmailConfig, t time.Time) (Snapshot, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return Snapshot{}, fmt.Errorf("creating backups directory: %w", err)
+	}
+	b, err := json.MarshalIndent(snapshotFile{
+		Time:    t,
+		Labels:  cfg.Labels,
+		Filters: cfg.Filters,
+	}, "", "  ")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	// Avoid overwriting snapshots taken in the same second.
+	name := t.UTC().Format(snapshotTimeFormat)
+	for i := 2; ; i++ {
+		p := filepath.Join(dir, name+snapshotExt)
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			name = fmt.Sprintf("%s-%d", t.UTC().Format(snapshotTimeFormat), i)
+			continue
+		}
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("creating snapshot: %w", err)
+		}
+		if _, err := f.Write(b); err != nil {
+			_ = f.Close()
+			return Snapshot{}, fmt.Errorf("writing snapshot: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return Snapshot{}, fmt.Errorf("writing snapshot: %w", err)
+		}
+		return Snapshot{Name: name, Path: p, Time: t}, nil
+	}
+}
+
+// List returns the snapshots in the directory, from the oldest.
+func List(dir string) ([]Snapshot, error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+
+	var res []Snapshot
+	for _, de := range des {
+		name, ok := strings.CutSuffix(de.Name(), snapshotExt)
+		if !ok || de.IsDir() {
+			continue
+		}
+		// The suffix of snapshots taken in the same second is ignored.
+		t, err := time.Parse(snapshotTimeFormat, name[:min(len(name), len(snapshotTimeFormat))])
+		if err != nil {
+			// Not a snapshot.
+			continue
+		}
+		res = append(res, Snapshot{Name: name, Path: filepath.Join(dir, de.Name()), Time: t})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		// Snapshots in the same second are ordered by suffix.
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		return a.Name < b.Name
+	})
+	return res, nil
+}
+
+// Load reads the config saved in the snapshot file.
+func Load(path string) (apply.GmailConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return apply.GmailConfig{}, fmt.Errorf("reading snapshot: %w", err)
+	}
+	var sf snapshotFile
+	if err := json.Unmarshal(b, &sf); err != nil {
+		return apply.GmailConfig{}, fmt.Errorf("decoding snapshot %q: %w", path, err)
+	}
+	return apply.GmailConfig{Labels: sf.Labels, Filters: sf.Filters}, nil
+}
+
+// Prune removes the snapshots not to be kept according to the retention.
+// The most recent snapshot is always kept.
+func Prune(dir string, r Retention, now time.Time) error {
+	snaps, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for i, s := range snaps {
+		last := i == len(snaps)-1
+		tooMany := r.Keep > 0 && len(snaps)-i > r.Keep
+		tooOld := r.MaxAge > 0 && now.Sub(s.Time) > r.MaxAge
+		if last || (!tooMany && !tooOld) {
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil {
+			return fmt.Errorf("removing old snapshot: %w", err)
+		}
+	}
+	return nil
+}
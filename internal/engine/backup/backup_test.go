@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func day(d int) time.Time {
+	return time.Date(2026, 1, d, 3, 4, 5, 0, time.UTC)
+}
+
+func names(snaps []Snapshot) []string {
+	var res []string
+	for _, s := range snaps {
+		res = append(res, s.Name)
+	}
+	return res
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	snaps, err := List(dir)
+	require.Nil(t, err)
+	assert.Empty(t, snaps)
+
+	cfg := apply.GmailConfig{
+		Labels: label.Labels{{ID: "l1", Name: "work"}},
+		Filters: filter.Filters{{
+			ID:       "f1",
+			Criteria: filter.Criteria{From: "a@b.com"},
+			Action:   filter.Actions{AddLabel: "work"},
+		}},
+	}
+	s1, err := Save(dir, cfg, day(1))
+	require.Nil(t, err)
+	// Same second: the first snapshot is not overwritten.
+	s2, err := Save(dir, apply.GmailConfig{}, day(1))
+	require.Nil(t, err)
+	assert.Equal(t, "20260101-030405", s1.Name)
+	assert.Equal(t, "20260101-030405-2", s2.Name)
+
+	// Other files are ignored.
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600))
+
+	snaps, err = List(dir)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"20260101-030405", "20260101-030405-2"}, names(snaps))
+
+	got, err := Load(s1.Path)
+	require.Nil(t, err)
+	assert.Equal(t, cfg, got)
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention Retention
+		want      []string
+	}{
+		{
+			name:      "keep",
+			retention: Retention{Keep: 2},
+			want:      []string{"20260104-030405", "20260105-030405"},
+		},
+		{
+			name:      "max age",
+			retention: Retention{Keep: 10, MaxAge: 48 * time.Hour},
+			want:      []string{"20260104-030405", "20260105-030405"},
+		},
+		{
+			name:      "keep last",
+			retention: Retention{Keep: 10, MaxAge: time.Hour},
+			want:      []string{"20260105-030405"},
+		},
+		{
+			name:      "no limit",
+			retention: Retention{Keep: 10},
+			want: []string{
+				"20260101-030405", "20260102-030405", "20260103-030405",
+				"20260104-030405", "20260105-030405",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for d := 1; d <= 5; d++ {
+				_, err := Save(dir, apply.GmailConfig{}, day(d))
+				require.Nil(t, err)
+			}
+			require.Nil(t, Prune(dir, tc.retention, day(6)))
+			snaps, err := List(dir)
+			require.Nil(t, err)
+			assert.Equal(t, tc.want, names(snaps))
+		})
+	}
+}
+
+func TestRetentionFromConfig(t *testing.T) {
+	zero := 0
+	assert.Equal(t, Retention{Keep: DefaultKeep}, RetentionFromConfig(nil))
+	assert.Equal(t, Retention{Keep: DefaultKeep, MaxAge: 72 * time.Hour},
+		RetentionFromConfig(&v1alpha3.ApplySettings{
+			Backups: &v1alpha3.BackupSettings{MaxAgeDays: 3},
+		}))
+	r := RetentionFromConfig(&v1alpha3.ApplySettings{
+		Backups: &v1alpha3.BackupSettings{Keep: &zero},
+	})
+	assert.False(t, r.Enabled())
+}
@@ -3,14 +3,20 @@ package cfgtest
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mbrt/gmailctl/internal/engine/parser"
 )
 
 // NewEvaluator creates a RuleEvaluator starting from a parser criteria.
 func NewEvaluator(criteria parser.CriteriaAST) (RuleEvaluator, error) {
-	v := evalBuilder{}
+	return newEvaluator(criteria, false)
+}
+
+func newEvaluator(criteria parser.CriteriaAST, inQuery bool) (RuleEvaluator, error) {
+	v := evalBuilder{inQuery: inQuery}
 	criteria.AcceptVisitor(&v)
 	return v.Res, v.Err
 }
@@ -18,12 +24,16 @@ func NewEvaluator(criteria parser.CriteriaAST) (RuleEvaluator, error) {
 type evalBuilder struct {
 	Res RuleEvaluator
 	Err error
+
+	// inQuery is true when the criteria comes from a parsed query. In this
+	// case 'query' leaves contain single operators (e.g. 'filename:pdf').
+	inQuery bool
 }
 
 func (r *evalBuilder) VisitNode(n *parser.Node) {
 	var children []RuleEvaluator
 	for _, c := range n.Children {
-		ce, err := NewEvaluator(c)
+		ce, err := newEvaluator(c, r.inQuery)
 		if err != nil {
 			r.Err = err
 			return
@@ -68,8 +78,12 @@ func (r *evalBuilder) VisitLeaf(n *parser.Leaf) {
 	case parser.FunctionHas:
 		rules = expandAll(n.Args, expandHas)
 	case parser.FunctionQuery:
-		r.Err = fmt.Errorf("unsupported unconstrained query: '%v'", n)
-		return
+		var err error
+		rules, err = expandAllErr(n.Args, r.expandQuery)
+		if err != nil {
+			r.Err = err
+			return
+		}
 	default:
 		r.Err = fmt.Errorf("unsupported function: %s", n.Function)
 		return
@@ -87,6 +101,126 @@ func expandAll(args []string, f func(arg string) RuleEvaluator) []RuleEvaluator
 	return res
 }
 
+// expandAllErr applies the given expander to all the arguments, stopping at
+// the first error.
+func expandAllErr(args []string, f func(arg string) (RuleEvaluator, error)) ([]RuleEvaluator, error) {
+	var res []RuleEvaluator
+	for _, arg := range args {
+		e, err := f(arg)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// expandQuery expands a query into the corresponding evaluators.
+//
+// Queries are parsed first and only the supported operators are allowed.
+func (r *evalBuilder) expandQuery(arg string) (RuleEvaluator, error) {
+	if r.inQuery {
+		// This is a single operator, as the query was already parsed.
+		return expandOperator(arg)
+	}
+	crit, err := parser.ParseQuery(arg)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported query: %w", err)
+	}
+	return newEvaluator(crit, true)
+}
+
+// expandOperator expands a Gmail search operator, in the form 'name:value'
+// into the corresponding evaluator.
+func expandOperator(arg string) (RuleEvaluator, error) {
+	name, value, _ := strings.Cut(arg, ":")
+	value = strings.Trim(value, `"`)
+
+	switch name {
+	case "deliveredto":
+		return emailField(matchFieldDeliveredTo, value), nil
+	case "filename":
+		return freeTextField(matchFieldAttachments, value), nil
+	case "rfc822msgid":
+		return headerNode{name: "Message-ID", expected: value}, nil
+	case "has":
+		if value != "attachment" {
+			return nil, fmt.Errorf("unsupported operator '%s'", arg)
+		}
+		return hasAttachmentNode{}, nil
+	case "larger", "size", "smaller":
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("operator '%s': %w", arg, err)
+		}
+		return sizeNode{bytes: size, smaller: name == "smaller"}, nil
+	case "older_than", "newer_than":
+		years, months, days, err := parseRelativeDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("operator '%s': %w", arg, err)
+		}
+		return dateNode{
+			ref: func() time.Time {
+				return timeNow().AddDate(-years, -months, -days)
+			},
+			before: name == "older_than",
+		}, nil
+	case "before", "older", "after", "newer":
+		d, err := time.Parse("2006/01/02", value)
+		if err != nil {
+			return nil, fmt.Errorf("operator '%s': expected a date in the format YYYY/MM/DD", arg)
+		}
+		return dateNode{
+			ref:    func() time.Time { return d },
+			before: name == "before" || name == "older",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator '%s'", arg)
+	}
+}
+
+// parseSize parses sizes in the form used by Gmail: bytes, or with a 'K'
+// or 'M' suffix (e.g. '10M').
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(strings.ToUpper(s), "K"):
+		mult = 1024
+		s = s[:len(s)-1]
+	case strings.HasSuffix(strings.ToUpper(s), "M"):
+		mult = 1024 * 1024
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("expected a size in bytes, optionally followed by 'K' or 'M'")
+	}
+	return n * mult, nil
+}
+
+// parseRelativeDate parses relative dates in the form used by Gmail: a
+// number followed by 'd' (days), 'm' (months) or 'y' (years).
+func parseRelativeDate(s string) (years, months, days int, err error) {
+	errFormat := errors.New("expected a number followed by 'd', 'm' or 'y'")
+	if len(s) < 2 {
+		return 0, 0, 0, errFormat
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, 0, 0, errFormat
+	}
+	switch s[len(s)-1] {
+	case 'd':
+		return 0, 0, n, nil
+	case 'm':
+		return 0, n, 0, nil
+	case 'y':
+		return n, 0, 0, nil
+	default:
+		return 0, 0, 0, errFormat
+	}
+}
+
 // expandTo expands the 'to' function into the corresponding evaluators.
 //
 // In Gmail, 'to' is a shortcut for (to || cc || bcc || list).
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"strings"
 
@@ -30,7 +31,7 @@ func NewFromParserRules(rs []parser.Rule) (Rules, error) {
 		if err != nil {
 			errs = errors.Combine(
 				errs,
-				fmt.Errorf("cannot evaluate criteria #%d: %w", i, err),
+				fmt.Errorf("cannot evaluate criteria of %s: %w", parser.RuleName(i, pr.Source), err),
 			)
 			continue
 		}
@@ -60,6 +61,7 @@ func (rs Rules) ExecTests(ts []v1alpha3.Test) Result {
 			failed = append(failed, FailedTest{
 				ID:     i,
 				Name:   t.Name,
+				Source: t.Source,
 				Errors: errs,
 			})
 		}
@@ -76,48 +78,129 @@ func (rs Rules) ExecTests(ts []v1alpha3.Test) Result {
 //
 // If the rules apply as expected by the test, no error is returned.
 func (rs Rules) ExecTest(t v1alpha3.Test) []error {
+	if err := validateTest(t); err != nil {
+		return []error{err}
+	}
+
 	var res error
 
 	for i, msg := range t.Messages {
-		expected, err := rs.MatchingActions(msg)
-		if err != nil {
+		if err := validateMessage(msg); err != nil {
 			res = errors.Combine(
 				res,
 				errors.WithDetails(
-					fmt.Errorf("message #%d: error evaluating matching filters: %w", i, err),
+					fmt.Errorf("message #%d is invalid: %w", i, err),
 					messageDetails(msg)),
 			)
 			continue
 		}
-		if expected.Equal(Actions(t.Actions)) {
-			// All good with this message.
+		got, err := rs.MatchingActions(msg)
+		if err != nil {
+			res = errors.Combine(
+				res,
+				errors.WithDetails(
+					fmt.Errorf("message #%d: error evaluating matching filters: %w", i, err),
+					messageDetails(msg)),
+			)
 			continue
 		}
+		res = errors.Combine(res, checkExpectations(i, msg, t, got))
+	}
 
-		// Report the error.
-		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
-			A:        difflib.SplitLines(reporting.Prettify(t.Actions, false)),
-			B:        difflib.SplitLines(reporting.Prettify(expected, false)),
-			FromFile: "want",
-			ToFile:   "got",
-			Context:  5,
-		})
-		if err != nil {
-			// The diff failing is not a big deal, but we should return
-			// something.
-			diff = fmt.Sprintf("<cannot compute diff>: %v", err)
-		}
+	return errors.Errors(res)
+}
+
+// checkExpectations checks the actions applied to a message against all the
+// expectations of the test.
+func checkExpectations(i int, msg v1alpha3.Message, t v1alpha3.Test, got Actions) error {
+	var res error
+
+	if t.ExpectNoMatch && !got.Equal(Actions{}) {
+		res = errors.Combine(
+			res,
+			errors.WithDetails(
+				fmt.Errorf("message #%d was expected to match no filters, but is going to get actions: %s", i,
+					reporting.Prettify(got, true)),
+				messageDetails(msg)),
+		)
+	}
+
+	if want, ok := exactExpectation(t); ok && !got.Equal(want) {
 		res = errors.Combine(
 			res,
 			errors.WithDetails(
 				fmt.Errorf("message #%d is going to get unexpected actions: %s", i,
-					reporting.Prettify(expected, true)),
+					reporting.Prettify(got, true)),
 				messageDetails(msg),
-				fmt.Sprintf("Actions:\n%s", strings.TrimRight(diff, "\n"))),
+				fmt.Sprintf("Actions:\n%s", actionsDiff(want, got, "want"))),
 		)
 	}
 
-	return errors.Errors(res)
+	if t.ExpectIncludes != nil {
+		want := Actions(*t.ExpectIncludes)
+		if missing := missingActions(got, want); !isEmpty(missing) {
+			res = errors.Combine(
+				res,
+				errors.WithDetails(
+					fmt.Errorf("message #%d is missing expected actions: %s", i,
+						reporting.Prettify(missing, true)),
+					messageDetails(msg),
+					fmt.Sprintf("Actions:\n%s", actionsDiff(want, got, "want (included)"))),
+			)
+		}
+	}
+
+	if t.ExpectExcludes != nil {
+		if common := commonActions(got, Actions(*t.ExpectExcludes)); !isEmpty(common) {
+			res = errors.Combine(
+				res,
+				errors.WithDetails(
+					fmt.Errorf("message #%d is going to get excluded actions: %s", i,
+						reporting.Prettify(common, true)),
+					messageDetails(msg),
+					fmt.Sprintf("Actions: %s", reporting.Prettify(got, true))),
+			)
+		}
+	}
+
+	return res
+}
+
+// exactExpectation returns the exact set of actions expected by the test,
+// if any.
+//
+// When no other expectation is specified, the test expects no actions.
+func exactExpectation(t v1alpha3.Test) (Actions, bool) {
+	if t.Actions != nil {
+		return Actions(*t.Actions), true
+	}
+	if t.ExpectIncludes == nil && t.ExpectExcludes == nil && !t.ExpectNoMatch {
+		return Actions{}, true
+	}
+	return Actions{}, false
+}
+
+func validateTest(t v1alpha3.Test) error {
+	if t.ExpectNoMatch && (t.Actions != nil || t.ExpectIncludes != nil) {
+		return errors.New("'expectNoMatch' cannot be combined with 'actions' or 'expectIncludes'")
+	}
+	return nil
+}
+
+func actionsDiff(want, got Actions, wantName string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(reporting.Prettify(want, false)),
+		B:        difflib.SplitLines(reporting.Prettify(got, false)),
+		FromFile: wantName,
+		ToFile:   "got",
+		Context:  5,
+	})
+	if err != nil {
+		// The diff failing is not a big deal, but we should return
+		// something.
+		return fmt.Sprintf("<cannot compute diff>: %v", err)
+	}
+	return strings.TrimRight(diff, "\n")
 }
 
 // MatchingActions returns the actions that would be applied by the rules if
@@ -168,6 +251,7 @@ func (r Result) String() string {
 type FailedTest struct {
 	ID     int
 	Name   string
+	Source string
 	Errors []error
 }
 
@@ -182,7 +266,12 @@ func (t FailedTest) dump(w io.Writer) {
 	if name == "" {
 		name = fmt.Sprintf("#%d", t.ID)
 	}
-	fmt.Fprintf(w, "\nFailed test %q:\n%+v\n", name, errors.Combine(t.Errors...))
+	if t.Source != "" {
+		name = fmt.Sprintf("%q (%s)", name, t.Source)
+	} else {
+		name = fmt.Sprintf("%q", name)
+	}
+	fmt.Fprintf(w, "\nFailed test %s:\n%+v\n", name, errors.Combine(t.Errors...))
 }
 
 // Actions represent the actions applied by a filter.
@@ -217,6 +306,68 @@ func (a Actions) Equal(a2 Actions) bool {
 	return a.Forward == a2.Forward
 }
 
+// missingActions returns the actions in want that are not going to be
+// applied.
+func missingActions(got, want Actions) Actions {
+	res := Actions{
+		Archive:  want.Archive && !got.Archive,
+		Delete:   want.Delete && !got.Delete,
+		MarkRead: want.MarkRead && !got.MarkRead,
+		Star:     want.Star && !got.Star,
+	}
+	if want.MarkSpam != nil && !triboolsEqual(want.MarkSpam, got.MarkSpam) {
+		res.MarkSpam = want.MarkSpam
+	}
+	if want.MarkImportant != nil && !triboolsEqual(want.MarkImportant, got.MarkImportant) {
+		res.MarkImportant = want.MarkImportant
+	}
+	if want.Category != "" && want.Category != got.Category {
+		res.Category = want.Category
+	}
+	for _, l := range want.Labels {
+		if !slices.Contains(got.Labels, l) {
+			res.Labels = append(res.Labels, l)
+		}
+	}
+	if want.Forward != "" && want.Forward != got.Forward {
+		res.Forward = want.Forward
+	}
+	return res
+}
+
+// commonActions returns the actions in excluded that are going to be
+// applied.
+func commonActions(got, excluded Actions) Actions {
+	res := Actions{
+		Archive:  excluded.Archive && got.Archive,
+		Delete:   excluded.Delete && got.Delete,
+		MarkRead: excluded.MarkRead && got.MarkRead,
+		Star:     excluded.Star && got.Star,
+	}
+	if excluded.MarkSpam != nil && triboolsEqual(excluded.MarkSpam, got.MarkSpam) {
+		res.MarkSpam = excluded.MarkSpam
+	}
+	if excluded.MarkImportant != nil && triboolsEqual(excluded.MarkImportant, got.MarkImportant) {
+		res.MarkImportant = excluded.MarkImportant
+	}
+	if excluded.Category != "" && excluded.Category == got.Category {
+		res.Category = excluded.Category
+	}
+	for _, l := range excluded.Labels {
+		if slices.Contains(got.Labels, l) {
+			res.Labels = append(res.Labels, l)
+		}
+	}
+	if excluded.Forward != "" && excluded.Forward == got.Forward {
+		res.Forward = excluded.Forward
+	}
+	return res
+}
+
+func isEmpty(a Actions) bool {
+	return v1alpha3.Actions(a).Empty()
+}
+
 func mergeActions(a1, a2 Actions) (Actions, error) {
 	res := Actions{
 		Archive:  a1.Archive || a2.Archive,
@@ -300,6 +451,18 @@ func stringSliceEqual(s1, s2 []string) bool {
 	return true
 }
 
+func validateMessage(msg v1alpha3.Message) error {
+	if msg.Date != "" {
+		if _, err := parseMessageDate(msg.Date); err != nil {
+			return fmt.Errorf("invalid date %q: expected RFC 3339 or YYYY-MM-DD format", msg.Date)
+		}
+	}
+	if msg.SizeBytes < 0 {
+		return fmt.Errorf("invalid negative size %d", msg.SizeBytes)
+	}
+	return nil
+}
+
 func messageDetails(msg v1alpha3.Message) string {
 	return fmt.Sprintf("Message: %s", reporting.Prettify(msg, false))
 }
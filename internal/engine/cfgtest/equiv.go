@@ -0,0 +1,158 @@
+package cfgtest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+// ActionDifference is an action applied differently by two sets of rules.
+type ActionDifference struct {
+	// Action describes the action (e.g. "label: work").
+	Action string
+	// Message is matched by the rules applying the action in only one of
+	// the two sets.
+	Message v1alpha3.Message
+	// Before and After are all the actions applied to the message by the
+	// two sets of rules.
+	Before, After Actions
+}
+
+// CompareRules checks whether two sets of rules apply the same actions to
+// every message, regardless of how the rules are structured.
+//
+// For every action, the criteria of all the rules applying it are compared
+// for equivalence. When they differ, a message is provided as a
+// counterexample. An error is returned if the rules cannot be analysed, or
+// if no counterexample is found without proving that none exists (see
+// ErrUndecided). In that case, the equivalence is unknown.
+func CompareRules(before, after []parser.Rule) ([]ActionDifference, error) {
+	beforeRules, err := NewFromParserRules(before)
+	if err != nil {
+		return nil, fmt.Errorf("analysing the old rules: %w", err)
+	}
+	afterRules, err := NewFromParserRules(after)
+	if err != nil {
+		return nil, fmt.Errorf("analysing the new rules: %w", err)
+	}
+
+	beforeCrit, afterCrit := criteriaByAction(before), criteriaByAction(after)
+	keys := map[string]bool{}
+	for k := range beforeCrit {
+		keys[k] = true
+	}
+	for k := range afterCrit {
+		keys[k] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+
+	var (
+		res  []ActionDifference
+		errs error
+	)
+	for _, k := range sortedKeys {
+		msg, found, err := findDifference(beforeCrit[k], afterCrit[k])
+		if err != nil {
+			errs = errors.Combine(errs, fmt.Errorf("action %q: %w", k, err))
+			continue
+		}
+		if !found {
+			continue
+		}
+		// Conflicting actions are already reported by the tests, so
+		// ignore them here.
+		beforeActions, _ := beforeRules.MatchingActions(msg)
+		afterActions, _ := afterRules.MatchingActions(msg)
+		res = append(res, ActionDifference{
+			Action:  k,
+			Message: msg,
+			Before:  beforeActions,
+			After:   afterActions,
+		})
+	}
+
+	return res, errs
+}
+
+// findDifference looks for a message matched by only one of the two
+// criteria. Nil criteria match no messages.
+func findDifference(a, b parser.CriteriaAST) (v1alpha3.Message, bool, error) {
+	switch {
+	case a == nil && b == nil:
+		return v1alpha3.Message{}, false, nil
+	case a == nil:
+		return FindMessage(b)
+	case b == nil:
+		return FindMessage(a)
+	}
+	msg, found, err := FindCounterexample(a, b)
+	if err != nil || found {
+		return msg, found, err
+	}
+	return FindCounterexample(b, a)
+}
+
+// criteriaByAction groups the criteria of the rules by the single actions
+// they apply.
+func criteriaByAction(rs []parser.Rule) map[string]parser.CriteriaAST {
+	children := map[string][]parser.CriteriaAST{}
+	for _, r := range rs {
+		for _, k := range actionKeys(r.Actions) {
+			children[k] = append(children[k], r.Criteria)
+		}
+	}
+
+	res := map[string]parser.CriteriaAST{}
+	for k, cs := range children {
+		if len(cs) == 1 {
+			res[k] = cs[0]
+			continue
+		}
+		res[k] = &parser.Node{
+			Operation: parser.OperationOr,
+			Children:  cs,
+		}
+	}
+	return res
+}
+
+// actionKeys splits the actions into single ones, named after their config
+// fields.
+func actionKeys(a parser.Actions) []string {
+	var res []string
+	if a.Archive {
+		res = append(res, "archive")
+	}
+	if a.Delete {
+		res = append(res, "delete")
+	}
+	if a.MarkRead {
+		res = append(res, "markRead")
+	}
+	if a.Star {
+		res = append(res, "star")
+	}
+	if a.MarkSpam != nil {
+		res = append(res, fmt.Sprintf("markSpam: %v", *a.MarkSpam))
+	}
+	if a.MarkImportant != nil {
+		res = append(res, fmt.Sprintf("markImportant: %v", *a.MarkImportant))
+	}
+	if a.Category != "" {
+		res = append(res, fmt.Sprintf("category: %s", a.Category))
+	}
+	for _, l := range a.Labels {
+		res = append(res, fmt.Sprintf("label: %s", l))
+	}
+	if a.Forward != "" {
+		res = append(res, fmt.Sprintf("forward: %s", a.Forward))
+	}
+	return res
+}
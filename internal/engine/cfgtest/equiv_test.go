@@ -0,0 +1,112 @@
+package cfgtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+func TestCompareRulesEquivalent(t *testing.T) {
+	before := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:{a b}"),
+			Actions:  parser.Actions{Archive: true, Labels: []string{"work"}},
+		},
+	}
+	// Restructured, but equivalent.
+	after := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:a"),
+			Actions:  parser.Actions{Archive: true, Labels: []string{"work"}},
+		},
+		{
+			Criteria: mustParseQuery(t, "{from:b (from:b subject:foo)}"),
+			Actions:  parser.Actions{Labels: []string{"work"}, Archive: true},
+		},
+	}
+
+	got, err := CompareRules(before, after)
+	require.Nil(t, err)
+	assert.Empty(t, got)
+}
+
+func TestCompareRulesDifferent(t *testing.T) {
+	before := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:{a b}"),
+			Actions:  parser.Actions{Archive: true, Labels: []string{"work"}},
+		},
+	}
+	after := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:{a b}"),
+			Actions:  parser.Actions{Archive: true},
+		},
+		{
+			Criteria: mustParseQuery(t, "from:a"),
+			Actions:  parser.Actions{Labels: []string{"work"}},
+		},
+		{
+			Criteria: mustParseQuery(t, "list:foo"),
+			Actions:  parser.Actions{Star: true},
+		},
+	}
+
+	got, err := CompareRules(before, after)
+	require.Nil(t, err)
+	assert.Equal(t, []ActionDifference{
+		{
+			Action:  "label: work",
+			Message: v1alpha3.Message{From: "b"},
+			Before:  Actions{Archive: true, Labels: []string{"work"}},
+			After:   Actions{Archive: true},
+		},
+		{
+			Action:  "star",
+			Message: v1alpha3.Message{Lists: []string{"foo"}},
+			Before:  Actions{},
+			After:   Actions{Star: true},
+		},
+	}, got)
+}
+
+func TestCompareRulesUndecided(t *testing.T) {
+	before := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:@x.com"),
+			Actions:  parser.Actions{Archive: true},
+		},
+	}
+	after := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:someone@x.com"),
+			Actions:  parser.Actions{Archive: true},
+		},
+	}
+
+	// No difference is found, but the rules are not proven equivalent.
+	got, err := CompareRules(before, after)
+	assert.Empty(t, got)
+	assert.True(t, errors.Is(err, ErrUndecided))
+}
+
+func TestCompareRulesUnsupported(t *testing.T) {
+	rules := []parser.Rule{
+		{
+			Criteria: &parser.Leaf{
+				Function: parser.FunctionFrom,
+				Grouping: parser.OperationNone,
+				Args:     []string{"a"},
+				IsRaw:    true,
+			},
+			Actions: parser.Actions{Archive: true},
+		},
+	}
+	_, err := CompareRules(rules, nil)
+	assert.NotNil(t, err)
+}
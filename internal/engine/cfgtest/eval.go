@@ -2,6 +2,7 @@ package cfgtest
 
 import (
 	"strings"
+	"time"
 
 	cfg "github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
 )
@@ -26,8 +27,20 @@ const (
 	matchFieldLists
 	matchFieldSubject
 	matchFieldBody
+	matchFieldDeliveredTo
+	matchFieldAttachments
 )
 
+// Date formats accepted in test messages.
+var messageDateFormats = []string{
+	time.RFC3339,
+	"2006-01-02",
+}
+
+// timeNow returns the current time. Relative date operators (e.g.
+// 'older_than') are evaluated against it.
+var timeNow = time.Now
+
 // RuleEvaluator represents a filter criteria able to evaluate if an email matches
 // its definition.
 type RuleEvaluator interface {
@@ -95,6 +108,11 @@ func (n funcNode) Match(msg cfg.Message) bool {
 		fields = []string{msg.Subject}
 	case matchFieldBody:
 		fields = []string{msg.Body}
+	case matchFieldDeliveredTo:
+		fields = append(fields, msg.DeliveredTo...)
+		fields = append(fields, headerValues(msg, "Delivered-To")...)
+	case matchFieldAttachments:
+		fields = msg.Attachments
 	}
 
 	for _, f := range fields {
@@ -119,6 +137,86 @@ func (n funcNode) Match(msg cfg.Message) bool {
 	return false
 }
 
+type headerNode struct {
+	name     string
+	expected string
+}
+
+func (n headerNode) Match(msg cfg.Message) bool {
+	for _, v := range headerValues(msg, n.name) {
+		if strings.EqualFold(strings.Trim(v, "<>"), n.expected) {
+			return true
+		}
+	}
+	return false
+}
+
+type hasAttachmentNode struct{}
+
+func (hasAttachmentNode) Match(msg cfg.Message) bool {
+	return len(msg.Attachments) > 0
+}
+
+type sizeNode struct {
+	bytes   int64
+	smaller bool
+}
+
+func (n sizeNode) Match(msg cfg.Message) bool {
+	if n.smaller {
+		return msg.SizeBytes < n.bytes
+	}
+	return msg.SizeBytes > n.bytes
+}
+
+// dateNode matches messages received before or after a certain time.
+//
+// The reference time is computed at match time, to support relative
+// dates.
+type dateNode struct {
+	ref    func() time.Time
+	before bool
+}
+
+func (n dateNode) Match(msg cfg.Message) bool {
+	if msg.Date == "" {
+		return false
+	}
+	d, err := parseMessageDate(msg.Date)
+	if err != nil {
+		return false
+	}
+	if n.before {
+		return d.Before(n.ref())
+	}
+	return !d.Before(n.ref())
+}
+
+func parseMessageDate(s string) (time.Time, error) {
+	var (
+		res time.Time
+		err error
+	)
+	for _, f := range messageDateFormats {
+		if res, err = time.Parse(f, s); err == nil {
+			return res, nil
+		}
+	}
+	return res, err
+}
+
+// headerValues returns the values of the given header, ignoring case in
+// its name.
+func headerValues(msg cfg.Message, name string) []string {
+	var res []string
+	for k, v := range msg.Headers {
+		if strings.EqualFold(k, name) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
 // normalizeField emulates Gmail normalization: @ and . are the same, and
 // the match is case insensitive.
 func normalizeField(a string) string {
@@ -2,6 +2,7 @@ package cfgtest
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -84,3 +85,133 @@ func TestParseEval(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryEval(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+	defer func() { timeNow = time.Now }()
+
+	tests := []struct {
+		name        string
+		query       string
+		message     cfg.Message
+		expectMatch bool
+	}{
+		{
+			name:        "has attachment",
+			query:       "has:attachment",
+			message:     cfg.Message{Attachments: []string{"a.pdf"}},
+			expectMatch: true,
+		},
+		{
+			name:        "no attachment",
+			query:       "has:attachment",
+			message:     cfg.Message{},
+			expectMatch: false,
+		},
+		{
+			name:        "filename",
+			query:       "filename:pdf from:me",
+			message:     cfg.Message{From: "me", Attachments: []string{"Report.PDF"}},
+			expectMatch: true,
+		},
+		{
+			name:        "larger",
+			query:       "larger:1M",
+			message:     cfg.Message{SizeBytes: 2 * 1024 * 1024},
+			expectMatch: true,
+		},
+		{
+			name:        "not smaller",
+			query:       "smaller:10K",
+			message:     cfg.Message{SizeBytes: 20 * 1024},
+			expectMatch: false,
+		},
+		{
+			name:        "older than",
+			query:       "older_than:1y",
+			message:     cfg.Message{Date: "2023-01-01"},
+			expectMatch: true,
+		},
+		{
+			name:        "not newer than",
+			query:       "newer_than:10d",
+			message:     cfg.Message{Date: "2024-05-01T10:00:00Z"},
+			expectMatch: false,
+		},
+		{
+			name:        "before",
+			query:       "before:2024/01/01",
+			message:     cfg.Message{Date: "2023-12-31"},
+			expectMatch: true,
+		},
+		{
+			name:        "delivered to",
+			query:       "deliveredto:alias@gmail.com",
+			message:     cfg.Message{DeliveredTo: []string{"alias@gmail.com"}},
+			expectMatch: true,
+		},
+		{
+			name:        "delivered to header",
+			query:       "deliveredto:alias@gmail.com",
+			message:     cfg.Message{Headers: map[string]string{"delivered-to": "alias@gmail.com"}},
+			expectMatch: true,
+		},
+		{
+			name:        "message id",
+			query:       "rfc822msgid:abc@mail.com",
+			message:     cfg.Message{Headers: map[string]string{"Message-ID": "<abc@mail.com>"}},
+			expectMatch: true,
+		},
+		{
+			name:        "negation in group",
+			query:       "(-from:a@x.com)",
+			message:     cfg.Message{From: "a@x.com"},
+			expectMatch: false,
+		},
+		{
+			name:        "negated first member of or",
+			query:       "{-from:a@x.com to:b@x.com}",
+			message:     cfg.Message{From: "c@x.com"},
+			expectMatch: true,
+		},
+		{
+			name:        "negated group",
+			query:       "-(from:a@x.com to:b@x.com)",
+			message:     cfg.Message{From: "a@x.com"},
+			expectMatch: true,
+		},
+		{
+			name:        "negated group matching",
+			query:       "-(from:a@x.com to:b@x.com)",
+			message:     cfg.Message{From: "a@x.com", To: []string{"b@x.com"}},
+			expectMatch: false,
+		},
+		{
+			name:        "negated free text",
+			query:       `{subject:foo -"unsubscribe now"}`,
+			message:     cfg.Message{Body: "click to unsubscribe now"},
+			expectMatch: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			eval, err := NewEvaluator(fn1(parser.FunctionQuery, tc.query))
+			if err != nil {
+				t.Fatalf("NewEvaluator failed: %v", err)
+			}
+			assert.Equal(t, tc.expectMatch, eval.Match(tc.message))
+		})
+	}
+}
+
+func TestUnsupportedQuery(t *testing.T) {
+	for _, q := range []string{"is:unread", "has:drive", "larger:big", "dinner AROUND 5"} {
+		t.Run(q, func(t *testing.T) {
+			_, err := NewEvaluator(fn1(parser.FunctionQuery, q))
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -33,7 +33,7 @@ func TestExec(t *testing.T) {
 			numErrs: 1,
 			expectedOut: `Failed: 1/1
 
-Failed test "both filters":
+Failed test "both filters" (invalid.jsonnet:35):
 message #0: error evaluating matching filters: conflicting filters detected: 'markImportant' is applied differently: got true and false
 Note:
 - Message: {
@@ -51,7 +51,7 @@ Note:
 			numErrs: 2,
 			expectedOut: `Failed: 2/2
 
-Failed test "wrong test":
+Failed test "wrong test" (fail.jsonnet:34):
 message #0: error evaluating matching filters: conflicting filters detected: 'markImportant' is applied differently: got true and false
 Note:
 - Message: {
@@ -63,7 +63,7 @@ Note:
     ]
   }
 
-Failed test "another wrong test":
+Failed test "another wrong test" (fail.jsonnet:46):
 multiple errors (2):
 - message #0 is going to get unexpected actions: {"markImportant":false}
   Note:
@@ -95,6 +95,53 @@ multiple errors (2):
     -  "markImportant": true
     +  "markImportant": false
      }
+`,
+		},
+		{
+			path:    "partial.jsonnet",
+			numErrs: 3,
+			expectedOut: `Failed: 3/5
+
+Failed test "wrong includes" (partial.jsonnet:47):
+message #0 is missing expected actions: {"markRead":true,"labels":["other"]}
+Note:
+- Message: {
+    "lists": [
+      "list1"
+    ]
+  }
+- Actions:
+  --- want (included)
+  +++ got
+  @@ -1,7 +1,6 @@
+   {
+  -  "markRead": true,
+  +  "archive": true,
+     "labels": [
+  -    "maillist",
+  -    "other"
+  +    "maillist"
+     ]
+   }
+
+Failed test "wrong excludes" (partial.jsonnet:57):
+message #0 is going to get excluded actions: {"markRead":true}
+Note:
+- Message: {
+    "lists": [
+      "list2"
+    ]
+  }
+- Actions: {"archive":true,"markRead":true,"labels":["maillist"]}
+
+Failed test "wrong no match" (partial.jsonnet:67):
+message #0 was expected to match no filters, but is going to get actions: {"archive":true,"labels":["maillist"]}
+Note:
+- Message: {
+    "lists": [
+      "list1"
+    ]
+  }
 `,
 		},
 	}
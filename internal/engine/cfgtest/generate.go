@@ -0,0 +1,450 @@
+package cfgtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+	"github.com/mbrt/gmailctl/internal/reporting"
+)
+
+// Maximum number of alternative messages kept for every node of the
+// criteria, to avoid exponential blowups on big rules.
+const maxCandidates = 4
+
+// GeneratedMessages contains messages synthesized from a rule criteria.
+type GeneratedMessages struct {
+	// Matching are messages matched by the criteria.
+	Matching []v1alpha3.Message
+	// NonMatching are messages similar to the matching ones, but not
+	// matched by the criteria.
+	NonMatching []v1alpha3.Message
+}
+
+// GenerateMessages synthesizes minimal messages that should and should not
+// match the given criteria.
+//
+// Messages are built by walking the criteria, and then verified with an
+// evaluator. Candidates not behaving as expected are discarded, so the
+// result can be empty for contradictory criteria. Criteria not supported by
+// the tests return an error.
+func GenerateMessages(criteria parser.CriteriaAST) (GeneratedMessages, error) {
+	eval, err := NewEvaluator(criteria)
+	if err != nil {
+		return GeneratedMessages{}, err
+	}
+	pos, neg, err := generateCandidates(criteria, false)
+	if err != nil {
+		return GeneratedMessages{}, err
+	}
+
+	var res GeneratedMessages
+	seen := map[string]bool{}
+	for _, m := range pos {
+		if k := messageKey(m); !seen[k] && eval.Match(m) {
+			seen[k] = true
+			res.Matching = append(res.Matching, m)
+		}
+	}
+	for _, m := range neg {
+		if k := messageKey(m); !seen[k] && !eval.Match(m) {
+			seen[k] = true
+			res.NonMatching = append(res.NonMatching, m)
+		}
+	}
+	return res, nil
+}
+
+// generateCandidates returns candidate messages matching (pos) and not
+// matching (neg) the criteria.
+func generateCandidates(criteria parser.CriteriaAST, inQuery bool) (pos, neg []v1alpha3.Message, err error) {
+	switch n := criteria.(type) {
+	case *parser.Node:
+		return generateNodeCandidates(n, inQuery)
+	case *parser.Leaf:
+		return generateLeafCandidates(n, inQuery)
+	default:
+		return nil, nil, fmt.Errorf("unknown criteria node %T", criteria)
+	}
+}
+
+func generateNodeCandidates(n *parser.Node, inQuery bool) (pos, neg []v1alpha3.Message, err error) {
+	var poss, negs [][]v1alpha3.Message
+	for _, c := range n.Children {
+		cpos, cneg, err := generateCandidates(c, inQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+		poss = append(poss, cpos)
+		negs = append(negs, cneg)
+	}
+
+	switch n.Operation {
+	case parser.OperationAnd:
+		return combineAll(poss), nearMisses(poss, negs), nil
+	case parser.OperationOr:
+		return firstOfEach(poss), combineAll(negs), nil
+	case parser.OperationNot:
+		if len(n.Children) != 1 {
+			return nil, nil, fmt.Errorf("unexpected children size for 'not' node: %d", len(n.Children))
+		}
+		return negs[0], poss[0], nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported operation %s", n.Operation)
+	}
+}
+
+func generateLeafCandidates(n *parser.Leaf, inQuery bool) (pos, neg []v1alpha3.Message, err error) {
+	if n.IsRaw {
+		return nil, nil, fmt.Errorf("unsupported 'raw query': %v", n)
+	}
+	if n.Function == parser.FunctionQuery && !inQuery {
+		// Parse the queries and treat them as nodes of an AND.
+		var children []parser.CriteriaAST
+		for _, arg := range n.Args {
+			crit, err := parser.ParseQuery(arg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unsupported query: %w", err)
+			}
+			children = append(children, crit)
+		}
+		return generateNodeCandidates(&parser.Node{
+			Operation: groupingOrAnd(n.Grouping),
+			Children:  children,
+		}, true)
+	}
+
+	var argPos [][]v1alpha3.Message
+	for _, arg := range n.Args {
+		m, err := messageForArg(n.Function, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		argPos = append(argPos, []v1alpha3.Message{m})
+	}
+	// The empty message doesn't match any function.
+	empty := []v1alpha3.Message{{}}
+
+	switch groupingOrAnd(n.Grouping) {
+	case parser.OperationOr:
+		return firstOfEach(argPos), empty, nil
+	case parser.OperationAnd:
+		negs := make([][]v1alpha3.Message, len(argPos))
+		for i := range negs {
+			negs[i] = empty
+		}
+		return combineAll(argPos), nearMisses(argPos, negs), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported grouping %s", n.Grouping)
+	}
+}
+
+// messageForArg returns a minimal message matching a single function argument.
+func messageForArg(fn parser.FunctionType, arg string) (v1alpha3.Message, error) {
+	var m v1alpha3.Message
+
+	switch fn {
+	case parser.FunctionFrom:
+		m.From = addressFor(arg)
+	case parser.FunctionTo:
+		m.To = []string{addressFor(arg)}
+	case parser.FunctionCc:
+		m.Cc = []string{addressFor(arg)}
+	case parser.FunctionBcc:
+		m.Bcc = []string{addressFor(arg)}
+	case parser.FunctionReplyTo:
+		m.ReplyTo = []string{addressFor(arg)}
+	case parser.FunctionList:
+		m.Lists = []string{addressFor(arg)}
+	case parser.FunctionSubject:
+		m.Subject = arg
+	case parser.FunctionHas:
+		m.Body = arg
+	case parser.FunctionQuery:
+		return messageForOperator(arg)
+	default:
+		return m, fmt.Errorf("unsupported function: %s", fn)
+	}
+
+	return m, nil
+}
+
+// messageForOperator returns a minimal message matching a Gmail operator.
+func messageForOperator(arg string) (v1alpha3.Message, error) {
+	var m v1alpha3.Message
+	// Make sure the operator is supported first.
+	if _, err := expandOperator(arg); err != nil {
+		return m, err
+	}
+	name, value, _ := strings.Cut(arg, ":")
+	value = strings.Trim(value, `"`)
+
+	switch name {
+	case "deliveredto":
+		m.DeliveredTo = []string{addressFor(value)}
+	case "filename":
+		m.Attachments = []string{value}
+	case "rfc822msgid":
+		m.Headers = map[string]string{"Message-ID": fmt.Sprintf("<%s>", value)}
+	case "has":
+		m.Attachments = []string{"attachment.pdf"}
+	case "larger", "size":
+		size, _ := parseSize(value)
+		m.SizeBytes = size + 1
+	case "smaller":
+		// Zero would be omitted, so make it as small as possible instead.
+		m.SizeBytes = 1
+	case "older_than":
+		years, months, days, _ := parseRelativeDate(value)
+		m.Date = timeNow().AddDate(-years-1, -months, -days).Format(time.DateOnly)
+	case "newer_than":
+		m.Date = timeNow().Format(time.DateOnly)
+	case "before", "older":
+		d, _ := time.Parse("2006/01/02", value)
+		m.Date = d.AddDate(0, 0, -1).Format(time.DateOnly)
+	case "after", "newer":
+		d, _ := time.Parse("2006/01/02", value)
+		m.Date = d.AddDate(0, 0, 1).Format(time.DateOnly)
+	}
+
+	return m, nil
+}
+
+// addressFor returns an email address matching the given argument, which can
+// also be a domain (e.g. '@gmail.com' or '*@gmail.com').
+func addressFor(arg string) string {
+	arg = strings.TrimPrefix(arg, "*")
+	if strings.HasPrefix(arg, "@") || strings.HasPrefix(arg, ".") {
+		return "someone" + arg
+	}
+	return arg
+}
+
+// combineAll merges together the first compatible candidates of every list,
+// producing messages that satisfy all of them at the same time.
+func combineAll(lists [][]v1alpha3.Message) []v1alpha3.Message {
+	res := []v1alpha3.Message{{}}
+	for _, l := range lists {
+		var next []v1alpha3.Message
+		for _, r := range res {
+			for _, m := range l {
+				if merged, ok := mergeMessages(r, m); ok {
+					next = append(next, merged)
+				}
+				if len(next) >= maxCandidates {
+					break
+				}
+			}
+			if len(next) >= maxCandidates {
+				break
+			}
+		}
+		res = next
+	}
+	return res
+}
+
+// nearMisses returns messages satisfying all the conditions except one.
+func nearMisses(pos, neg [][]v1alpha3.Message) []v1alpha3.Message {
+	var res []v1alpha3.Message
+	for i := range pos {
+		lists := make([][]v1alpha3.Message, len(pos))
+		copy(lists, pos)
+		lists[i] = neg[i]
+		res = append(res, combineAll(lists)...)
+	}
+	return res
+}
+
+// firstOfEach returns the first candidate of every list.
+func firstOfEach(lists [][]v1alpha3.Message) []v1alpha3.Message {
+	var res []v1alpha3.Message
+	for _, l := range lists {
+		if len(l) > 0 {
+			res = append(res, l[0])
+		}
+		if len(res) >= maxCandidates {
+			break
+		}
+	}
+	return res
+}
+
+// mergeMessages returns a message containing the fields of both. If the
+// messages have incompatible fields, false is returned.
+func mergeMessages(m1, m2 v1alpha3.Message) (v1alpha3.Message, bool) {
+	res := v1alpha3.Message{
+		To:          concat(m1.To, m2.To),
+		Cc:          concat(m1.Cc, m2.Cc),
+		Bcc:         concat(m1.Bcc, m2.Bcc),
+		ReplyTo:     concat(m1.ReplyTo, m2.ReplyTo),
+		Lists:       concat(m1.Lists, m2.Lists),
+		Subject:     joinText(m1.Subject, m2.Subject),
+		Body:        joinText(m1.Body, m2.Body),
+		DeliveredTo: concat(m1.DeliveredTo, m2.DeliveredTo),
+		Attachments: concat(m1.Attachments, m2.Attachments),
+	}
+	var ok bool
+	if res.From, ok = mergeSingle(m1.From, m2.From); !ok {
+		return res, false
+	}
+	if res.Date, ok = mergeSingle(m1.Date, m2.Date); !ok {
+		return res, false
+	}
+	size, ok := mergeSingle(sizeString(m1.SizeBytes), sizeString(m2.SizeBytes))
+	if !ok {
+		return res, false
+	}
+	res.SizeBytes, _ = strconv.ParseInt(size, 10, 64)
+	for _, hs := range []map[string]string{m1.Headers, m2.Headers} {
+		for k, v := range hs {
+			if old, ok := res.Headers[k]; ok && old != v {
+				return res, false
+			}
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers[k] = v
+		}
+	}
+	return res, true
+}
+
+func mergeSingle(s1, s2 string) (string, bool) {
+	res, err := mergeStrings(s1, s2)
+	return res, err == nil
+}
+
+func sizeString(s int64) string {
+	if s == 0 {
+		return ""
+	}
+	return strconv.FormatInt(s, 10)
+}
+
+func concat(s1, s2 []string) []string {
+	if len(s1)+len(s2) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(s1)+len(s2))
+	res = append(res, s1...)
+	return append(res, s2...)
+}
+
+func joinText(s1, s2 string) string {
+	if s1 == "" {
+		return s2
+	}
+	if s2 == "" {
+		return s1
+	}
+	return s1 + " " + s2
+}
+
+func groupingOrAnd(op parser.OperationType) parser.OperationType {
+	if op == parser.OperationNone {
+		return parser.OperationAnd
+	}
+	return op
+}
+
+func messageKey(m v1alpha3.Message) string {
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// GenerateTests synthesizes tests for the given rules.
+//
+// For every rule, a test with the generated matching messages expects the
+// rule actions to be applied, and tests with the non matching messages
+// expect the actions currently applied by the other rules. Rules that can't
+// be tested are skipped and reported in the returned error, together with
+// generated messages that trigger conflicting filters. If some rules can't
+// be evaluated, the expected actions can't be computed, so no tests are
+// generated and the error is returned.
+func GenerateTests(prs []parser.Rule) ([]v1alpha3.Test, error) {
+	rules, err := NewFromParserRules(prs)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		res  []v1alpha3.Test
+		errs error
+	)
+
+	for i, pr := range prs {
+		ruleName := parser.RuleName(i, pr.Source)
+		gen, err := GenerateMessages(pr.Criteria)
+		if err != nil {
+			errs = errors.Combine(errs, fmt.Errorf("%s: %w", ruleName, err))
+			continue
+		}
+		if len(gen.Matching) == 0 {
+			errs = errors.Combine(errs, fmt.Errorf("%s: cannot find any matching message", ruleName))
+		}
+
+		var matching []v1alpha3.Message
+		for _, m := range gen.Matching {
+			if _, err := rules.MatchingActions(m); err != nil {
+				errs = errors.Combine(errs, errors.WithDetails(
+					fmt.Errorf("%s: %w", ruleName, err), messageDetails(m)))
+				continue
+			}
+			matching = append(matching, m)
+		}
+		if len(matching) > 0 {
+			actions := v1alpha3.Actions(pr.Actions)
+			res = append(res, v1alpha3.Test{
+				Name:           fmt.Sprintf("rule #%d matches", i),
+				Messages:       matching,
+				ExpectIncludes: &actions,
+			})
+		}
+
+		// Group the non matching messages by the actions they get.
+		var (
+			keys   []string
+			groups = map[string]*v1alpha3.Test{}
+		)
+		for _, m := range gen.NonMatching {
+			got, err := rules.MatchingActions(m)
+			if err != nil {
+				errs = errors.Combine(errs, errors.WithDetails(
+					fmt.Errorf("%s: %w", ruleName, err), messageDetails(m)))
+				continue
+			}
+			key := reporting.Prettify(got, true)
+			t, ok := groups[key]
+			if !ok {
+				t = &v1alpha3.Test{Name: fmt.Sprintf("rule #%d doesn't match", i)}
+				if isEmpty(got) {
+					t.ExpectNoMatch = true
+				} else {
+					actions := v1alpha3.Actions(got)
+					t.Actions = &actions
+				}
+				if len(keys) > 0 {
+					t.Name = fmt.Sprintf("%s (%d)", t.Name, len(keys)+1)
+				}
+				groups[key] = t
+				keys = append(keys, key)
+			}
+			t.Messages = append(t.Messages, m)
+		}
+		for _, k := range keys {
+			res = append(res, *groups[k])
+		}
+	}
+
+	// Double check that the generated tests pass.
+	if tres := rules.ExecTests(res); !tres.OK {
+		errs = errors.Combine(errs, fmt.Errorf("generated tests are failing: %s", tres))
+	}
+
+	return res, errs
+}
@@ -0,0 +1,138 @@
+package cfgtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+func TestGenerateMessages(t *testing.T) {
+	tests := []struct {
+		name        string
+		criteria    parser.CriteriaAST
+		matching    []cfg.Message
+		nonMatching []cfg.Message
+	}{
+		{
+			name:        "single",
+			criteria:    fn1(parser.FunctionFrom, "@google.com"),
+			matching:    []cfg.Message{{From: "someone@google.com"}},
+			nonMatching: []cfg.Message{{}},
+		},
+		{
+			name: "and not",
+			criteria: and(
+				fn1(parser.FunctionList, "list1"),
+				&parser.Node{
+					Operation: parser.OperationNot,
+					Children:  []parser.CriteriaAST{fn1(parser.FunctionTo, "me")},
+				},
+			),
+			matching: []cfg.Message{{Lists: []string{"list1"}}},
+			nonMatching: []cfg.Message{
+				{},
+				{To: []string{"me"}, Lists: []string{"list1"}},
+			},
+		},
+		{
+			name: "or",
+			criteria: or(
+				fn(parser.FunctionSubject, parser.OperationAnd, "foo", "bar"),
+				fn1(parser.FunctionQuery, "has:attachment larger:1K"),
+			),
+			matching: []cfg.Message{
+				{Subject: "foo bar"},
+				{Attachments: []string{"attachment.pdf"}, SizeBytes: 1025},
+			},
+			nonMatching: []cfg.Message{
+				{Subject: "bar", SizeBytes: 1025},
+				{Subject: "bar", Attachments: []string{"attachment.pdf"}},
+				{Subject: "foo", SizeBytes: 1025},
+				{Subject: "foo", Attachments: []string{"attachment.pdf"}},
+			},
+		},
+		{
+			name: "contradiction",
+			criteria: and(
+				fn1(parser.FunctionFrom, "a"),
+				fn1(parser.FunctionFrom, "b"),
+			),
+			nonMatching: []cfg.Message{{From: "b"}, {From: "a"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GenerateMessages(tc.criteria)
+			require.Nil(t, err)
+			assert.Equal(t, tc.matching, got.Matching)
+			assert.Equal(t, tc.nonMatching, got.NonMatching)
+		})
+	}
+}
+
+func TestGenerateTests(t *testing.T) {
+	rules := []parser.Rule{
+		{
+			Criteria: fn1(parser.FunctionList, "list1"),
+			Actions:  parser.Actions{Archive: true},
+		},
+		{
+			Criteria: and(fn1(parser.FunctionList, "list1"), fn1(parser.FunctionFrom, "me")),
+			Actions:  parser.Actions{Star: true},
+		},
+	}
+	tests, err := GenerateTests(rules)
+	require.Nil(t, err)
+
+	archive := cfg.Actions{Archive: true}
+	star := cfg.Actions{Star: true}
+	expected := []cfg.Test{
+		{
+			Name:           "rule #0 matches",
+			Messages:       []cfg.Message{{Lists: []string{"list1"}}},
+			ExpectIncludes: &archive,
+		},
+		{
+			Name:          "rule #0 doesn't match",
+			Messages:      []cfg.Message{{}},
+			ExpectNoMatch: true,
+		},
+		{
+			Name:           "rule #1 matches",
+			Messages:       []cfg.Message{{From: "me", Lists: []string{"list1"}}},
+			ExpectIncludes: &star,
+		},
+		{
+			Name:          "rule #1 doesn't match",
+			Messages:      []cfg.Message{{From: "me"}},
+			ExpectNoMatch: true,
+		},
+		{
+			Name:     "rule #1 doesn't match (2)",
+			Messages: []cfg.Message{{Lists: []string{"list1"}}},
+			Actions:  &archive,
+		},
+	}
+	assert.Equal(t, expected, tests)
+}
+
+func TestGenerateTestsUnsupported(t *testing.T) {
+	rules := []parser.Rule{
+		{
+			Criteria: fn1(parser.FunctionList, "list1"),
+			Actions:  parser.Actions{Archive: true},
+		},
+		{
+			Criteria: fn1(parser.FunctionQuery, "is:starred"),
+			Actions:  parser.Actions{Star: true},
+		},
+	}
+	tests, err := GenerateTests(rules)
+	assert.NotNil(t, err)
+	assert.Nil(t, tests)
+}
@@ -0,0 +1,90 @@
+package cfgtest
+
+import (
+	"fmt"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+// Overlap describes two rules that can match the same message.
+type Overlap struct {
+	// First and Second are the indexes of the rules, with First < Second.
+	First, Second int
+	// Witness is a message matched by both rules.
+	Witness v1alpha3.Message
+	// FirstImpliesSecond is true when every message matched by the first
+	// rule is also matched by the second.
+	FirstImpliesSecond bool
+	// SecondImpliesFirst is true when every message matched by the second
+	// rule is also matched by the first.
+	SecondImpliesFirst bool
+	// Conflict is set when the actions of the rules cannot be applied
+	// together.
+	Conflict error
+}
+
+// AnalyzeOverlaps looks for all the pairs of rules that can match the same
+// message.
+//
+// Rules that cannot be analysed are skipped and reported in the returned
+// error. Overlaps and implications are reported only when they can be
+// decided.
+func AnalyzeOverlaps(rs []parser.Rule) ([]Overlap, error) {
+	var (
+		res  []Overlap
+		errs error
+	)
+
+	supported := make([]bool, len(rs))
+	for i, r := range rs {
+		if _, err := NewEvaluator(r.Criteria); err != nil {
+			errs = errors.Combine(errs, fmt.Errorf("%s: %w", parser.RuleName(i, r.Source), err))
+			continue
+		}
+		supported[i] = true
+	}
+
+	for i := range rs {
+		for j := i + 1; j < len(rs); j++ {
+			if !supported[i] || !supported[j] {
+				continue
+			}
+			o, ok, err := analyzePair(rs, i, j)
+			if err != nil {
+				errs = errors.Combine(errs, fmt.Errorf("%s and %s: %w",
+					parser.RuleName(i, rs[i].Source), parser.RuleName(j, rs[j].Source), err))
+				continue
+			}
+			if ok {
+				res = append(res, o)
+			}
+		}
+	}
+
+	return res, errs
+}
+
+func analyzePair(rs []parser.Rule, i, j int) (Overlap, bool, error) {
+	ri, rj := rs[i], rs[j]
+	witness, ok, err := FindCommonMessage(ri.Criteria, rj.Criteria)
+	if errors.Is(err, ErrUndecided) {
+		return Overlap{}, false, nil
+	}
+	if err != nil || !ok {
+		return Overlap{}, false, err
+	}
+
+	res := Overlap{
+		First:   i,
+		Second:  j,
+		Witness: witness,
+	}
+	res.FirstImpliesSecond = Implies(ri.Criteria, rj.Criteria)
+	res.SecondImpliesFirst = Implies(rj.Criteria, ri.Criteria)
+	if _, err := mergeActions(Actions(ri.Actions), Actions(rj.Actions)); err != nil {
+		res.Conflict = err
+	}
+	return res, true, nil
+}
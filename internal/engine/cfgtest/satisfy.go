@@ -0,0 +1,388 @@
+package cfgtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+// Maximum number of steps spent looking for a message, before giving up.
+const maxSolverSteps = 100000
+
+var (
+	// ErrTooComplex is returned when the criteria are too complex to be
+	// analysed.
+	ErrTooComplex = errors.New("criteria too complex to analyse")
+	// ErrUndecided is returned when no message was found, but the criteria
+	// could not be proven contradictory either.
+	ErrUndecided = errors.New("cannot decide whether a matching message exists")
+)
+
+// FindMessage looks for a message matched by the given criteria.
+//
+// The search is symbolic: every search term is treated as an independent
+// condition, satisfied by adding the corresponding field to the message.
+// Candidates are then verified with an evaluator, so the returned messages
+// are always matched by the criteria.
+//
+// False is returned only when no message can exist, because the criteria
+// require some term to both match and not match. The search doesn't model
+// how different terms depend on each other (e.g. 'from:a@b.com' and
+// 'from:b.com'), so when no candidate works out otherwise, ErrUndecided is
+// returned.
+func FindMessage(criteria parser.CriteriaAST) (v1alpha3.Message, bool, error) {
+	eval, err := NewEvaluator(criteria)
+	if err != nil {
+		return v1alpha3.Message{}, false, err
+	}
+	f, err := toFormula(criteria, false, false)
+	if err != nil {
+		return v1alpha3.Message{}, false, err
+	}
+	s := solver{final: eval}
+	st, res := s.solve([]formula{f}, solverState{})
+	if s.steps > maxSolverSteps {
+		return v1alpha3.Message{}, false, ErrTooComplex
+	}
+	switch res {
+	case searchFound:
+		return st.msg, true, nil
+	case searchRefuted:
+		return v1alpha3.Message{}, false, nil
+	default:
+		return v1alpha3.Message{}, false, ErrUndecided
+	}
+}
+
+// Implies returns true if every message matched by a is also matched by b.
+//
+// Only implications that can be proven are reported, i.e. when the
+// criteria of a contradict the negation of b in every possible way. The
+// search terms are compared as they are, so terms that can't be evaluated
+// (e.g. raw queries) are supported too. False is returned when the
+// implication doesn't hold, or when it can't be decided.
+func Implies(a, b parser.CriteriaAST) bool {
+	f, err := toFormula(counterexampleCriteria(a, b), false, false)
+	if err != nil {
+		return false
+	}
+	// No final evaluator: only refutations are looked for.
+	s := solver{}
+	_, res := s.solve([]formula{f}, solverState{})
+	return res == searchRefuted && s.steps <= maxSolverSteps
+}
+
+// FindCommonMessage looks for a message matched by both criteria.
+func FindCommonMessage(a, b parser.CriteriaAST) (v1alpha3.Message, bool, error) {
+	return FindMessage(&parser.Node{
+		Operation: parser.OperationAnd,
+		Children:  []parser.CriteriaAST{a, b},
+	})
+}
+
+// FindCounterexample looks for a message matched by a, but not by b.
+//
+// If none is found and no error is returned, every message matched by a is
+// also matched by b.
+func FindCounterexample(a, b parser.CriteriaAST) (v1alpha3.Message, bool, error) {
+	return FindMessage(counterexampleCriteria(a, b))
+}
+
+// counterexampleCriteria returns the criteria matching the messages matched
+// by a, but not by b.
+func counterexampleCriteria(a, b parser.CriteriaAST) parser.CriteriaAST {
+	return &parser.Node{
+		Operation: parser.OperationAnd,
+		Children: []parser.CriteriaAST{
+			a,
+			&parser.Node{
+				Operation: parser.OperationNot,
+				Children:  []parser.CriteriaAST{b},
+			},
+		},
+	}
+}
+
+// formula is a criteria in negation normal form: negations are applied only
+// to single search terms.
+type formula struct {
+	op       parser.OperationType
+	children []formula
+	// lit is set only for terms, i.e. when op is none.
+	lit *literal
+}
+
+// literal is a single search term, possibly negated.
+type literal struct {
+	// key identifies the search term, regardless of the negation.
+	key string
+	// eval and msg are nil for terms that can't be evaluated, which are
+	// only compared by key.
+	eval    RuleEvaluator
+	msg     v1alpha3.Message
+	negated bool
+}
+
+// opaqueLiteral returns a literal for a term that can't be evaluated.
+func opaqueLiteral(n *parser.Leaf, arg string, negated bool) formula {
+	return formula{lit: &literal{key: termKey(n, arg), negated: negated}}
+}
+
+// termKey identifies a single search term. Gmail searches are case
+// insensitive.
+func termKey(n *parser.Leaf, arg string) string {
+	return fmt.Sprintf("%t:%s:%s", n.IsRaw, n.Function, strings.ToLower(arg))
+}
+
+func toFormula(criteria parser.CriteriaAST, negated, inQuery bool) (formula, error) {
+	switch n := criteria.(type) {
+	case *parser.Node:
+		if n.Operation == parser.OperationNot {
+			if len(n.Children) != 1 {
+				return formula{}, fmt.Errorf("unexpected children size for 'not' node: %d", len(n.Children))
+			}
+			return toFormula(n.Children[0], !negated, inQuery)
+		}
+		return nodeFormula(n.Operation, n.Children, negated, inQuery)
+	case *parser.Leaf:
+		return leafFormula(n, negated, inQuery)
+	default:
+		return formula{}, fmt.Errorf("unknown criteria node %T", criteria)
+	}
+}
+
+func nodeFormula(op parser.OperationType, children []parser.CriteriaAST, negated, inQuery bool) (formula, error) {
+	if op != parser.OperationAnd && op != parser.OperationOr {
+		return formula{}, fmt.Errorf("unsupported operation %s", op)
+	}
+	if negated {
+		// De Morgan.
+		op = dualOperation(op)
+	}
+	res := formula{op: op}
+	for _, c := range children {
+		f, err := toFormula(c, negated, inQuery)
+		if err != nil {
+			return formula{}, err
+		}
+		res.children = append(res.children, f)
+	}
+	return res, nil
+}
+
+// leafFormula returns the formula for a search term. Terms that can't be
+// evaluated are kept as opaque literals.
+func leafFormula(n *parser.Leaf, negated, inQuery bool) (formula, error) {
+	if n.Function == parser.FunctionQuery && !inQuery && !n.IsRaw {
+		var children []parser.CriteriaAST
+		for _, arg := range n.Args {
+			crit, err := parser.ParseQuery(arg)
+			if err != nil {
+				return opaqueLeafFormula(n, negated), nil
+			}
+			children = append(children, crit)
+		}
+		return nodeFormula(groupingOrAnd(n.Grouping), children, negated, true)
+	}
+
+	var children []formula
+	for _, arg := range n.Args {
+		children = append(children, argFormula(n, arg, negated, inQuery))
+	}
+	return groupFormula(n, children, negated), nil
+}
+
+func opaqueLeafFormula(n *parser.Leaf, negated bool) formula {
+	var children []formula
+	for _, arg := range n.Args {
+		children = append(children, opaqueLiteral(n, arg, negated))
+	}
+	return groupFormula(n, children, negated)
+}
+
+func argFormula(n *parser.Leaf, arg string, negated, inQuery bool) formula {
+	if n.IsRaw {
+		return opaqueLiteral(n, arg, negated)
+	}
+	single := &parser.Leaf{
+		Function: n.Function,
+		Grouping: parser.OperationNone,
+		Args:     []string{arg},
+	}
+	eval, err := newEvaluator(single, inQuery)
+	if err != nil {
+		return opaqueLiteral(n, arg, negated)
+	}
+	m, err := messageForArg(n.Function, arg)
+	if err != nil {
+		return opaqueLiteral(n, arg, negated)
+	}
+	return formula{
+		lit: &literal{key: termKey(n, arg), eval: eval, msg: m, negated: negated},
+	}
+}
+
+// groupFormula groups the formulas of the arguments of a leaf.
+func groupFormula(n *parser.Leaf, children []formula, negated bool) formula {
+	if len(children) == 1 {
+		return children[0]
+	}
+	op := groupingOrAnd(n.Grouping)
+	if negated {
+		op = dualOperation(op)
+	}
+	return formula{op: op, children: children}
+}
+
+func dualOperation(op parser.OperationType) parser.OperationType {
+	if op == parser.OperationAnd {
+		return parser.OperationOr
+	}
+	return parser.OperationAnd
+}
+
+type solver struct {
+	// final verifies the complete candidate messages. If nil, no message
+	// is looked for, only contradictions.
+	final RuleEvaluator
+	steps int
+}
+
+// searchResult is the outcome of the search of a message.
+type searchResult int
+
+const (
+	// searchUndecided means that no message was found, but it might exist.
+	searchUndecided searchResult = iota
+	// searchFound means that a message was found.
+	searchFound
+	// searchRefuted means that no message can exist.
+	searchRefuted
+)
+
+// solverState is the partial message built so far, together with the terms
+// it needs to satisfy.
+type solverState struct {
+	msg v1alpha3.Message
+	pos []*literal
+	neg []*literal
+	// stuck is set when the message can't be built anymore. The terms are
+	// still collected, to find contradictions.
+	stuck bool
+}
+
+// solve looks for a message satisfying all the goals, by backtracking over
+// the alternatives of 'or' nodes. Alternatives are refuted only when they
+// require a term to both match and not match.
+func (s *solver) solve(goals []formula, st solverState) (solverState, searchResult) {
+	s.steps++
+	if s.steps > maxSolverSteps {
+		return st, searchUndecided
+	}
+	if len(goals) == 0 {
+		if st.stuck || s.final == nil || !s.final.Match(st.msg) {
+			return st, searchUndecided
+		}
+		return st, searchFound
+	}
+
+	g, rest := goals[0], goals[1:]
+	switch g.op {
+	case parser.OperationAnd:
+		return s.solve(append(append([]formula{}, g.children...), rest...), st)
+	case parser.OperationOr:
+		res := searchRefuted
+		for _, c := range g.children {
+			cst, cres := s.solve(append([]formula{c}, rest...), st)
+			switch {
+			case cres == searchFound:
+				return cst, cres
+			case cres == searchUndecided && s.final == nil:
+				// Only looking for contradictions: this one is missing.
+				return st, cres
+			case cres == searchUndecided:
+				res = searchUndecided
+			}
+		}
+		return st, res
+	default:
+		if st.contradicts(g.lit) {
+			return st, searchRefuted
+		}
+		return s.solve(rest, st.add(g.lit))
+	}
+}
+
+// contradicts returns true if the state requires the opposite of the given
+// literal.
+func (st solverState) contradicts(lit *literal) bool {
+	opposite := st.neg
+	if lit.negated {
+		opposite = st.pos
+	}
+	for _, l := range opposite {
+		if l.key == lit.key {
+			return true
+		}
+	}
+	return false
+}
+
+// add returns a new state that satisfies also the given literal.
+func (st solverState) add(lit *literal) solverState {
+	if lit.negated {
+		st.neg = append(st.neg[:len(st.neg):len(st.neg)], lit)
+	} else {
+		st.pos = append(st.pos[:len(st.pos):len(st.pos)], lit)
+	}
+	switch {
+	case st.stuck:
+		return st
+	case lit.eval == nil:
+		st.stuck = true
+		return st
+	case lit.negated:
+		st.stuck = lit.eval.Match(st.msg)
+		return st
+	case lit.eval.Match(st.msg):
+		return st
+	}
+
+	m, ok := mergeMessages(st.msg, lit.msg)
+	if !ok {
+		// Single valued fields are in conflict: try to replace them with
+		// the values of the new term (e.g. 'from:a@b.com' can replace the
+		// sender of 'from:b.com').
+		base := st.msg
+		if lit.msg.From != "" {
+			base.From = ""
+		}
+		if lit.msg.Date != "" {
+			base.Date = ""
+		}
+		if lit.msg.SizeBytes != 0 {
+			base.SizeBytes = 0
+		}
+		if m, ok = mergeMessages(base, lit.msg); !ok {
+			st.stuck = true
+			return st
+		}
+	}
+	for _, l := range st.pos {
+		if !l.eval.Match(m) {
+			st.stuck = true
+			return st
+		}
+	}
+	for _, l := range st.neg {
+		if l.eval.Match(m) {
+			st.stuck = true
+			return st
+		}
+	}
+	st.msg = m
+	return st
+}
@@ -0,0 +1,221 @@
+package cfgtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+func mustParseQuery(t *testing.T, q string) parser.CriteriaAST {
+	t.Helper()
+	res, err := parser.ParseQuery(q)
+	require.Nil(t, err)
+	return res
+}
+
+func TestFindMessage(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected v1alpha3.Message
+		found    bool
+		err      error
+	}{
+		{
+			query:    "from:a@b.com subject:hello",
+			expected: v1alpha3.Message{From: "a@b.com", Subject: "hello"},
+			found:    true,
+		},
+		{
+			query:    "{from:a@b.com to:me} -from:a@b.com",
+			expected: v1alpha3.Message{To: []string{"me"}},
+			found:    true,
+		},
+		{
+			// The more specific sender replaces the domain.
+			query:    "from:@b.com from:a@b.com",
+			expected: v1alpha3.Message{From: "a@b.com"},
+			found:    true,
+		},
+		{
+			// Contradictory, but the relation between the terms is not
+			// modelled.
+			query: "from:a@b.com -from:@b.com",
+			err:   ErrUndecided,
+		},
+		{
+			query: "from:a@b.com from:c@d.com",
+			err:   ErrUndecided,
+		},
+		{
+			query: "from:a@b.com -from:A@b.com",
+			found: false,
+		},
+		{
+			query: "list:foo -list:{foo bar}",
+			found: false,
+		},
+		{
+			query:    "-list:{foo bar}",
+			expected: v1alpha3.Message{},
+			found:    true,
+		},
+		{
+			query:    "larger:10K filename:pdf",
+			expected: v1alpha3.Message{SizeBytes: 10241, Attachments: []string{"pdf"}},
+			found:    true,
+		},
+		{
+			query: "larger:10K smaller:5K",
+			err:   ErrUndecided,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			crit := &parser.Leaf{
+				Function: parser.FunctionQuery,
+				Grouping: parser.OperationNone,
+				Args:     []string{tc.query},
+			}
+			got, found, err := FindMessage(crit)
+			require.Equal(t, tc.err, err)
+			assert.Equal(t, tc.found, found)
+			if tc.found {
+				assert.Equal(t, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFindCounterexample(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		found bool
+		err   error
+	}{
+		{"from:a subject:x", "from:a", false, nil},
+		{"from:a", "from:a subject:x", true, nil},
+		{"from:{a b}", "{from:a from:b list:c}", false, nil},
+		// Domain and address.
+		{"from:a@b.com", "from:@b.com", false, ErrUndecided},
+		{"from:@b.com", "from:a@b.com", true, nil},
+		{"-from:@b.com", "-from:a@b.com", false, ErrUndecided},
+		{"from:@x.com", "from:someone@x.com", false, ErrUndecided},
+		// Substring and exact match.
+		{`subject:"hello world"`, "subject:hello", false, ErrUndecided},
+		{"subject:hello", `subject:"hello world"`, true, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.a+" -> "+tc.b, func(t *testing.T) {
+			m, found, err := FindCounterexample(mustParseQuery(t, tc.a), mustParseQuery(t, tc.b))
+			require.Equal(t, tc.err, err)
+			assert.Equal(t, tc.found, found, "counterexample: %+v", m)
+		})
+	}
+}
+
+func TestImplies(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"from:a", "from:a", true},
+		{"from:a", "from:A", true},
+		{"from:a", "from:b", false},
+		{"from:a to:b", "from:a", true},
+		{"from:a", "from:a to:b", false},
+		{"from:a", "from:{a b}", true},
+		{"from:{a b}", "from:a", false},
+		{"from:{a b}", "{from:b from:a list:c}", true},
+		{"-from:{a b}", "-from:a", true},
+		{"-from:a", "-from:{a b}", false},
+		{"subject:(x y) list:z", "subject:x", true},
+		{"{from:a to:b} cc:c", "{from:a to:b}", true},
+		// Undecided implications are not reported.
+		{"from:a@x.com", "from:@x.com", false},
+		{"from:@x.com", "from:a@x.com", false},
+		{`subject:"hello world"`, "subject:hello", false},
+		{"subject:hello", `subject:"hello world"`, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.a+" -> "+tc.b, func(t *testing.T) {
+			got := Implies(mustParseQuery(t, tc.a), mustParseQuery(t, tc.b))
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestImpliesUnsupported(t *testing.T) {
+	raw := &parser.Leaf{
+		Function: parser.FunctionQuery,
+		Grouping: parser.OperationNone,
+		Args:     []string{"is:starred"},
+		IsRaw:    true,
+	}
+	withList := &parser.Node{
+		Operation: parser.OperationAnd,
+		Children:  []parser.CriteriaAST{raw, mustParseQuery(t, "list:a")},
+	}
+	// Terms that can't be evaluated are still compared.
+	assert.True(t, Implies(withList, raw))
+	assert.False(t, Implies(raw, withList))
+}
+
+func TestUnsupportedCriteria(t *testing.T) {
+	_, _, err := FindMessage(&parser.Leaf{
+		Function: parser.FunctionFrom,
+		Grouping: parser.OperationNone,
+		Args:     []string{"a"},
+		IsRaw:    true,
+	})
+	assert.NotNil(t, err)
+}
+
+func TestAnalyzeOverlaps(t *testing.T) {
+	rules := []parser.Rule{
+		{
+			Criteria: mustParseQuery(t, "from:{a@x.com b@x.com}"),
+			Actions:  parser.Actions{Category: "updates"},
+			Source:   "config.jsonnet:3",
+		},
+		{
+			Criteria: mustParseQuery(t, "from:a@x.com subject:news"),
+			Actions:  parser.Actions{Category: "forums"},
+		},
+		{
+			Criteria: mustParseQuery(t, "from:c@x.com"),
+			Actions:  parser.Actions{Archive: true},
+		},
+		{
+			Criteria: mustParseQuery(t, "from:*@x.com"),
+			Actions:  parser.Actions{Star: true},
+		},
+	}
+
+	got, err := AnalyzeOverlaps(rules)
+	require.Nil(t, err)
+	require.Len(t, got, 4)
+
+	assert.Equal(t, 0, got[0].First)
+	assert.Equal(t, 1, got[0].Second)
+	assert.Equal(t, v1alpha3.Message{From: "a@x.com", Subject: "news"}, got[0].Witness)
+	assert.False(t, got[0].FirstImpliesSecond)
+	assert.True(t, got[0].SecondImpliesFirst)
+	assert.NotNil(t, got[0].Conflict)
+
+	var pairs [][2]int
+	for _, o := range got {
+		pairs = append(pairs, [2]int{o.First, o.Second})
+	}
+	assert.Equal(t, [][2]int{{0, 1}, {0, 3}, {1, 3}, {2, 3}}, pairs)
+	// The wildcard is not modelled, so the implication can't be proven.
+	assert.False(t, got[3].FirstImpliesSecond)
+	assert.False(t, got[3].SecondImpliesFirst)
+	assert.Nil(t, got[3].Conflict)
+}
@@ -0,0 +1,117 @@
+package cfgtest
+
+import (
+	"fmt"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+// VerifyExport checks that the Gmail filters generated from the given rules
+// match the same test messages as the rules themselves.
+//
+// The tests evaluate the rules directly, while Gmail evaluates the query
+// generated from them. The generated queries are parsed back and evaluated
+// on all the test messages, to make sure the two agree. Every disagreement
+// is returned as an error.
+//
+// Rules that cannot be evaluated are skipped.
+func VerifyExport(rs []parser.Rule, ts []v1alpha3.Test) error {
+	var msgs []v1alpha3.Message
+	for _, t := range ts {
+		msgs = append(msgs, t.Messages...)
+	}
+
+	var res error
+	for i, rule := range rs {
+		ruleEval, err := NewEvaluator(rule.Criteria)
+		if err != nil {
+			// Unsupported by the tests anyways.
+			continue
+		}
+		queries, err := generatedQueries(rule)
+		if err != nil {
+			res = errors.Combine(res, fmt.Errorf("%s: %w", parser.RuleName(i, rule.Source), err))
+			continue
+		}
+		queryEval, err := newQueriesEvaluator(queries)
+		if err != nil {
+			res = errors.Combine(res, errors.WithDetails(
+				fmt.Errorf("%s: cannot evaluate the generated filters: %w", parser.RuleName(i, rule.Source), err),
+				queriesDetails(queries)))
+			continue
+		}
+
+		for _, msg := range msgs {
+			want, got := ruleEval.Match(msg), queryEval.Match(msg)
+			if want == got {
+				continue
+			}
+			res = errors.Combine(res, errors.WithDetails(
+				fmt.Errorf("%s: %s", parser.RuleName(i, rule.Source), disagreement(want)),
+				queriesDetails(queries),
+				messageDetails(msg)))
+		}
+	}
+
+	return res
+}
+
+// generatedQueries returns the Gmail search queries of the filters generated
+// by the rule.
+func generatedQueries(rule parser.Rule) ([]string, error) {
+	fs, err := filter.FromRule(rule, filter.DefaultSizeLimit)
+	if err != nil {
+		return nil, fmt.Errorf("generating filters: %w", err)
+	}
+	// Multiple labels produce multiple filters with the same criteria.
+	var res []string
+	seen := map[string]bool{}
+	for _, f := range fs {
+		q := f.Criteria.ToGmailSearch()
+		if !seen[q] {
+			seen[q] = true
+			res = append(res, q)
+		}
+	}
+	return res, nil
+}
+
+// newQueriesEvaluator creates an evaluator matching any of the given queries.
+//
+// This is how Gmail works with filters split into multiple ones.
+func newQueriesEvaluator(queries []string) (RuleEvaluator, error) {
+	var children []RuleEvaluator
+	for _, q := range queries {
+		e, err := NewEvaluator(&parser.Leaf{
+			Function: parser.FunctionQuery,
+			Grouping: parser.OperationNone,
+			Args:     []string{q},
+		})
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, e)
+	}
+	return orNode{children}, nil
+}
+
+func queriesDetails(queries []string) string {
+	if len(queries) == 1 {
+		return fmt.Sprintf("Query: %s", queries[0])
+	}
+	res := "Queries:"
+	for _, q := range queries {
+		res += "\n" + q
+	}
+	return res
+}
+
+func disagreement(ruleMatch bool) string {
+	if ruleMatch {
+		return "message matched by the rule, but not by the generated filters"
+	}
+	return "message matched by the generated filters, but not by the rule"
+}
@@ -0,0 +1,79 @@
+package cfgtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+	"github.com/mbrt/gmailctl/internal/errors"
+)
+
+func TestVerifyExportConsistent(t *testing.T) {
+	cfg := readConfig(t, "pass.jsonnet")
+	pres, err := apply.FromConfig(cfg)
+	assert.Nil(t, err)
+	assert.Nil(t, VerifyExport(pres.Rules, cfg.Tests))
+}
+
+func TestVerifyExportDisagreement(t *testing.T) {
+	rules := []parser.Rule{
+		{
+			// The quote is not escaped in the generated query, so Gmail
+			// interprets it as the beginning of a phrase.
+			Criteria: fn1(parser.FunctionSubject, `a"b`),
+			Actions:  parser.Actions{Archive: true},
+		},
+	}
+	tests := []v1alpha3.Test{
+		{
+			Messages: []v1alpha3.Message{
+				{Subject: `a"b`},
+				{Subject: "a b"},
+			},
+		},
+	}
+	err := VerifyExport(rules, tests)
+	assert.Len(t, errors.Errors(err), 1)
+	assert.Equal(t, "rule #0: message matched by the generated filters, but not by the rule", err.Error())
+	assert.Contains(t, errors.Details(err), `Query: subject:a"b`)
+}
+
+func TestVerifyExportNegatedFirstMember(t *testing.T) {
+	rules := []parser.Rule{
+		{
+			// Generated as 'subject:hello {-from:a@x.com to:b@x.com}'.
+			Criteria: and(
+				fn1(parser.FunctionSubject, "hello"),
+				or(
+					not(fn1(parser.FunctionFrom, "a@x.com")),
+					fn1(parser.FunctionTo, "b@x.com"),
+				),
+			),
+			Actions: parser.Actions{Archive: true},
+		},
+		{
+			// Generated with a negated first member in an AND group.
+			Criteria: or(
+				and(
+					not(fn1(parser.FunctionFrom, "a@x.com")),
+					fn1(parser.FunctionSubject, "hello"),
+				),
+				fn1(parser.FunctionTo, "b@x.com"),
+			),
+			Actions: parser.Actions{Star: true},
+		},
+	}
+	tests := []v1alpha3.Test{
+		{
+			Messages: []v1alpha3.Message{
+				{From: "c@x.com", Subject: "hello"},
+				{From: "a@x.com", Subject: "hello"},
+				{From: "a@x.com", To: []string{"b@x.com"}, Subject: "hello"},
+			},
+		},
+	}
+	assert.Nil(t, VerifyExport(rules, tests))
+}
@@ -5,11 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
 
-	"github.com/google/go-jsonnet"
-
 	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
 	"github.com/mbrt/gmailctl/internal/errors"
 )
@@ -49,14 +46,12 @@ func ReadFile(path, libPath string) (v1alpha3.Config, error) {
 
 // ReadJsonnet parses a buffer containing a jsonnet config.
 //
-// The path is used to resolve imports.
+// The path is used to resolve imports. Rules and tests are annotated with
+// their position in the config files, relative to the directory of the
+// path.
 func ReadJsonnet(p string, buf []byte) (v1alpha3.Config, error) {
 	var res v1alpha3.Config
-	vm := jsonnet.MakeVM()
-	vm.Importer(&jsonnet.FileImporter{
-		JPaths: []string{path.Dir(p)},
-	})
-	jstr, err := vm.EvaluateAnonymousSnippet(p, string(buf))
+	jstr, srcs, err := evaluateJsonnet(p, buf)
 	if err != nil {
 		return res, fmt.Errorf("parsing jsonnet: %w", err)
 	}
@@ -68,8 +63,11 @@ func ReadJsonnet(p string, buf []byte) (v1alpha3.Config, error) {
 		return res, errors.WithDetails(fmt.Errorf("unsupported config version: %s", version),
 			unsupportedHelp)
 	}
-	err = jsonUnmarshalStrict([]byte(jstr), &res)
-	return res, err
+	if err := jsonUnmarshalStrict([]byte(jstr), &res); err != nil {
+		return res, err
+	}
+	srcs.apply(&res)
+	return res, nil
 }
 
 func readJSONVersion(js string) (string, error) {
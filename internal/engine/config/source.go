@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+)
+
+const (
+	// sourceField is a hidden field added to rules and tests, to keep track
+	// of their position in the config files. Being hidden, it's not part of
+	// the evaluated JSON.
+	sourceField = "__gmailctl_source"
+	// configVar is the external variable bound to the config, while its
+	// sources are collected.
+	configVar = "gmailctl-config"
+	// libFile is the name of the library shipped with gmailctl. The rules
+	// built by it are not defined by the user, so they get no position.
+	libFile = "gmailctl.libsonnet"
+)
+
+// sourcesSnippet evaluates the config together with the positions of its
+// rules and tests, as two separate JSON outputs.
+var sourcesSnippet = fmt.Sprintf(`
+local cfg = std.extVar('%s');
+local sources(field) =
+  local xs = if std.isObject(cfg) then std.get(cfg, field, []) else [];
+  if std.isArray(xs) then [
+    if std.isObject(x) then std.get(x, '%s', '') else ''
+    for x in xs
+  ] else [];
+{
+  config: cfg,
+  sources: { rules: sources('rules'), tests: sources('tests') },
+}
+`, configVar, sourceField)
+
+// evaluateJsonnet evaluates the config and returns its JSON representation,
+// together with the positions of rules and tests.
+//
+// Positions are added to the parsed code of the config and of its imports,
+// so the code itself is left untouched and errors refer to the original
+// positions.
+func evaluateJsonnet(p string, buf []byte) (string, configSources, error) {
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnet.FileImporter{
+		JPaths: []string{filepath.Dir(p)},
+	})
+
+	node, err := jsonnet.SnippetToAST(p, string(buf))
+	if err != nil {
+		return "", configSources{}, fmt.Errorf("%s", vm.ErrorFormatter.Format(err))
+	}
+	a := sourceAnnotator{
+		vm:      vm,
+		baseDir: filepath.Dir(p),
+		visited: map[string]bool{},
+	}
+	a.annotate(node, "", filepath.Base(p))
+	vm.ExtNode(configVar, node)
+
+	out, err := vm.EvaluateAnonymousSnippetMulti(p, sourcesSnippet)
+	if err != nil {
+		return "", configSources{}, err
+	}
+	var srcs configSources
+	if err := json.Unmarshal([]byte(out["sources"]), &srcs); err != nil {
+		return "", configSources{}, fmt.Errorf("reading sources: %w", err)
+	}
+	return out["config"], srcs, nil
+}
+
+// sourceAnnotator adds the source positions to the parsed config files.
+type sourceAnnotator struct {
+	vm *jsonnet.VM
+	// baseDir is the directory the paths are reported relative to.
+	baseDir string
+	visited map[string]bool
+}
+
+// annotate adds the source position to every object literal that defines a
+// rule (i.e. both 'filter' and 'actions') or a test (i.e. 'messages'), and
+// does the same for the imported files.
+//
+// The config is evaluated as an anonymous snippet, so its imports are
+// resolved as such: importedFrom is empty for the config itself.
+func (a sourceAnnotator) annotate(node ast.Node, importedFrom, display string) {
+	visitNodes(node, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.DesugaredObject:
+			if display != "" && isSourceTracked(n) {
+				addSource(n, fmt.Sprintf("%s:%d", display, n.Loc().Begin.Line))
+			}
+		case *ast.Import:
+			if importedFrom == "" {
+				n.LocRange.FileName = ""
+			}
+			a.annotateImport(importedFrom, n.File.Value)
+		}
+	})
+}
+
+func (a sourceAnnotator) annotateImport(importedFrom, importedPath string) {
+	contents, foundAt, err := a.vm.ImportData(importedFrom, importedPath)
+	if err != nil || a.visited[foundAt] {
+		return
+	}
+	a.visited[foundAt] = true
+	// Files that don't parse must be left to the evaluation to report:
+	// importing them here would cache the failure.
+	if _, err := jsonnet.SnippetToAST(foundAt, contents); err != nil {
+		return
+	}
+	node, _, err := a.vm.ImportAST(importedFrom, importedPath)
+	if err != nil {
+		return
+	}
+	display := displayPath(a.baseDir, foundAt)
+	if filepath.Base(foundAt) == libFile {
+		display = ""
+	}
+	a.annotate(node, foundAt, display)
+}
+
+// displayPath returns the path relative to the base directory, if the file
+// is inside it.
+func displayPath(baseDir, p string) string {
+	rel, err := filepath.Rel(baseDir, p)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return p
+	}
+	return rel
+}
+
+func visitNodes(node ast.Node, f func(ast.Node)) {
+	if node == nil {
+		return
+	}
+	f(node)
+	for _, child := range toolutils.Children(node) {
+		visitNodes(child, f)
+	}
+}
+
+func isSourceTracked(obj *ast.DesugaredObject) bool {
+	fields := map[string]bool{}
+	for _, field := range obj.Fields {
+		if name, ok := field.Name.(*ast.LiteralString); ok {
+			fields[name.Value] = true
+		}
+	}
+	return !fields[sourceField] && ((fields["filter"] && fields["actions"]) || fields["messages"])
+}
+
+func addSource(obj *ast.DesugaredObject, pos string) {
+	obj.Fields = append(obj.Fields, ast.DesugaredObjectField{
+		Name:     &ast.LiteralString{Value: sourceField, Kind: ast.StringDouble},
+		Body:     &ast.LiteralString{Value: pos, Kind: ast.StringDouble},
+		LocRange: obj.LocRange,
+		Hide:     ast.ObjectFieldHidden,
+	})
+}
+
+type configSources struct {
+	Rules []string `json:"rules"`
+	Tests []string `json:"tests"`
+}
+
+// apply sets the sources into the parsed config.
+func (s configSources) apply(cfg *v1alpha3.Config) {
+	if len(s.Rules) == len(cfg.Rules) {
+		for i, r := range s.Rules {
+			cfg.Rules[i].Source = r
+		}
+	}
+	if len(s.Tests) == len(cfg.Tests) {
+		for i, t := range s.Tests {
+			cfg.Tests[i].Source = t
+		}
+	}
+}
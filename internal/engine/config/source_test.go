@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSources(t *testing.T) {
+	cfg, err := ReadFile("testdata/sources/config.jsonnet", "")
+	require.Nil(t, err)
+
+	var rules []string
+	for _, r := range cfg.Rules {
+		rules = append(rules, r.Source)
+	}
+	// Rules not defined by a single object literal get no source.
+	assert.Equal(t, []string{
+		"config.jsonnet:8",
+		"lib.libsonnet:2",
+		"",
+		"config.jsonnet:14",
+	}, rules)
+	assert.Equal(t, "config.jsonnet:17", cfg.Tests[0].Source)
+}
+
+func TestReadErrorPositions(t *testing.T) {
+	_, err := ReadFile("testdata/sources/error.jsonnet", "")
+	require.NotNil(t, err)
+	// The position must refer to the original code, not the annotated one.
+	assert.Contains(t, err.Error(), "error.jsonnet:4:23-34")
+}
+
+func TestReadSourcesHidden(t *testing.T) {
+	code := `
+local rule = { filter: { from: 'a@b.com' }, actions: { archive: true } };
+{
+  version: 'v1alpha3',
+  rules: [rule],
+  tests: [{
+    messages: [{ from: std.join(',', std.objectFields(rule)) }],
+    actions: { archive: true },
+  }],
+}`
+	cfg, err := ReadJsonnet("dir/a.jsonnet", []byte(code))
+	require.Nil(t, err)
+	// The source position is not visible to the config.
+	assert.Equal(t, "actions,filter", cfg.Tests[0].Messages[0].From)
+	assert.Equal(t, "a.jsonnet:2", cfg.Rules[0].Source)
+	assert.Equal(t, "a.jsonnet:6", cfg.Tests[0].Source)
+}
+
+func TestReadSourcesChained(t *testing.T) {
+	cfg, err := ReadFile("testdata/sources/chain.jsonnet", "")
+	require.Nil(t, err)
+	require.Len(t, cfg.Rules, 2)
+	// The first rule is left as it is, while the others are rebuilt by the
+	// gmailctl library, so they have no position.
+	assert.Equal(t, "chain.jsonnet:6", cfg.Rules[0].Source)
+	assert.Equal(t, "", cfg.Rules[1].Source)
+}
@@ -0,0 +1,6 @@
+{
+  archive(from):: {
+    filter: { from: from },
+    actions: { archive: true },
+  },
+}
@@ -17,6 +17,55 @@ type Config struct {
 	Labels  []Label `json:"labels,omitempty"`
 	Rules   []Rule  `json:"rules"`
 	Tests   []Test  `json:"tests,omitempty"`
+
+	// Apply contains optional settings for the apply command.
+	Apply *ApplySettings `json:"apply,omitempty"`
+	// Unmanaged selects the filters and labels in Gmail not managed by
+	// the config. These are never removed.
+	Unmanaged *Unmanaged `json:"unmanaged,omitempty"`
+}
+
+// Unmanaged selects the filters and labels in Gmail that are created
+// outside of gmailctl (e.g. by hand in the Gmail UI).
+type Unmanaged struct {
+	Filters []UnmanagedFilter `json:"filters,omitempty"`
+	// Labels contains patterns of label names (e.g. 'Shared/**').
+	Labels []string `json:"labels,omitempty"`
+}
+
+// UnmanagedFilter matches the filters with all the given properties.
+// At least one of them is required.
+type UnmanagedFilter struct {
+	// Label is a pattern of the name of the label applied by the filter.
+	Label string `json:"label,omitempty"`
+	// Criteria is a pattern of the criteria of the filter, in Gmail
+	// search syntax (e.g. 'from:*@example.com*').
+	Criteria string `json:"criteria,omitempty"`
+	// Forward is a pattern of the address the filter forwards to.
+	Forward string `json:"forward,omitempty"`
+}
+
+// ApplySettings restrict the changes that apply is allowed to make, to
+// protect from mistakes in the config, and set how to back up the Gmail
+// settings before applying.
+type ApplySettings struct {
+	// MaxChanges is the maximum number of filters and labels to add,
+	// modify or remove.
+	MaxChanges *int `json:"maxChanges,omitempty"`
+	// MaxDeletions is the maximum number of filters and labels to remove.
+	MaxDeletions *int `json:"maxDeletions,omitempty"`
+	// Backups sets the retention of the backups of the Gmail settings,
+	// taken before every apply.
+	Backups *BackupSettings `json:"backups,omitempty"`
+}
+
+// BackupSettings sets which backups to keep.
+type BackupSettings struct {
+	// Keep is the number of backups to keep (20 by default). Zero
+	// disables the backups.
+	Keep *int `json:"keep,omitempty"`
+	// MaxAgeDays removes the backups older than this number of days.
+	MaxAgeDays int `json:"maxAgeDays,omitempty"`
 }
 
 // FilterNode represents a piece of a Gmail filter.
@@ -90,6 +139,13 @@ func (f FilterNode) NonEmptyFields() []string {
 type Rule struct {
 	Filter  FilterNode `json:"filter"`
 	Actions Actions    `json:"actions"`
+	// Tags are optional names to refer to a group of rules (e.g. to
+	// apply only them).
+	Tags []string `json:"tags,omitempty"`
+
+	// Source is the position of the rule in the config files
+	// (e.g. 'config.jsonnet:12'), when known.
+	Source string `json:"-"`
 }
 
 // Author represents the owner of the gmail account.
@@ -126,6 +182,16 @@ func (a Actions) Empty() bool {
 type Label struct {
 	Name  string      `json:"name"`
 	Color *LabelColor `json:"color,omitempty"`
+	// LabelList is the visibility of the label in the label list:
+	// 'show', 'hide' or 'showIfUnread'.
+	LabelList string `json:"labelList,omitempty"`
+	// MessageList is the visibility of the label in the message list:
+	// 'show' or 'hide'.
+	MessageList string `json:"messageList,omitempty"`
+	// PreviousNames are the names the label had before. A label in
+	// Gmail with one of these names is renamed, instead of being
+	// deleted and created again.
+	PreviousNames []string `json:"previousNames,omitempty"`
 }
 
 // LabelColor is the color of a label.
@@ -138,11 +204,26 @@ type LabelColor struct {
 }
 
 // Test represents the intended actions applied to a set of emails.
+//
+// If no expectation is specified, the messages are expected to get no
+// actions at all.
 type Test struct {
 	// Name is an optional name used for error reporting.
 	Name     string    `json:"name,omitempty"`
 	Messages []Message `json:"messages"`
-	Actions  Actions   `json:"actions"`
+	// Actions is the exact set of actions expected.
+	Actions *Actions `json:"actions,omitempty"`
+	// ExpectIncludes contains actions that must be applied, alongside
+	// any others.
+	ExpectIncludes *Actions `json:"expectIncludes,omitempty"`
+	// ExpectExcludes contains actions that must not be applied.
+	ExpectExcludes *Actions `json:"expectExcludes,omitempty"`
+	// ExpectNoMatch requires the messages to match no rules.
+	ExpectNoMatch bool `json:"expectNoMatch,omitempty"`
+
+	// Source is the position of the test in the config files
+	// (e.g. 'config.jsonnet:12'), when known.
+	Source string `json:"-"`
 }
 
 // Message represents the contents and metadata of an email.
@@ -155,6 +236,18 @@ type Message struct {
 	Lists   []string `json:"lists,omitempty"`
 	Subject string   `json:"subject,omitempty"`
 	Body    string   `json:"body,omitempty"`
+
+	// DeliveredTo contains the addresses in the Delivered-To headers.
+	DeliveredTo []string `json:"deliveredTo,omitempty"`
+	// Headers contains additional raw headers of the email.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Date is the date the email was received, in RFC 3339 format or
+	// as a plain date (YYYY-MM-DD).
+	Date string `json:"date,omitempty"`
+	// SizeBytes is the size of the email in bytes.
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+	// Attachments contains the file names of the attachments.
+	Attachments []string `json:"attachments,omitempty"`
 }
 
 func jsonTagName(t reflect.StructTag) string {
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+// canonicalCriteria returns criteria equivalent to the given ones, written
+// in a canonical form.
+//
+// Gmail can re-serialize the criteria of the filters it stores, for example
+// by reordering the terms of an OR. Criteria differing only in the order of
+// their terms have the same canonical form. Only the criteria that parse and
+// generate back exactly as they are can be trusted to be understood, so all
+// the others are returned unchanged.
+func canonicalCriteria(c Criteria) Criteria {
+	tree, err := parser.ParseQuery(criteriaQuery(c))
+	if err != nil {
+		return c
+	}
+	if rt, err := GenerateCriteria(tree); err != nil || rt != c {
+		return c
+	}
+	canonicalizeTree(tree)
+	res, err := GenerateCriteria(tree)
+	if err != nil {
+		return c
+	}
+	return res
+}
+
+// criteriaQuery joins all the fields of the criteria into a single query.
+// The query is appended as it is, as the implicit AND between the fields
+// binds less than any operator in it.
+func criteriaQuery(c Criteria) string {
+	var parts []string
+	if c.From != "" {
+		parts = append(parts, fmt.Sprintf("from:(%s)", c.From))
+	}
+	if c.To != "" {
+		parts = append(parts, fmt.Sprintf("to:(%s)", c.To))
+	}
+	if c.Subject != "" {
+		parts = append(parts, fmt.Sprintf("subject:(%s)", c.Subject))
+	}
+	if c.Query != "" {
+		parts = append(parts, c.Query)
+	}
+	return strings.Join(parts, " ")
+}
+
+// canonicalizeTree sorts the arguments of the leaves and the children of the
+// nodes, so that equivalent trees are generated in the same way.
+//
+// The tree is expected to be already simplified.
+func canonicalizeTree(tree parser.CriteriaAST) {
+	switch n := tree.(type) {
+	case *parser.Leaf:
+		if len(n.Args) > 1 {
+			n.Args = sortedUnique(n.Args)
+		}
+	case *parser.Node:
+		for _, c := range n.Children {
+			canonicalizeTree(c)
+		}
+		if n.Operation == parser.OperationNot {
+			return
+		}
+		keys := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			// Errors are caught when generating the whole tree.
+			keys[i], _ = generateCriteriaAsString(c)
+		}
+		sort.Sort(byKey{n.Children, keys})
+	}
+}
+
+func sortedUnique(a []string) []string {
+	res := append([]string{}, a...)
+	sort.Strings(res)
+	j := 0
+	for i := range res {
+		if i == 0 || res[i] != res[j-1] {
+			res[j] = res[i]
+			j++
+		}
+	}
+	return res[:j]
+}
+
+// byKey sorts criteria by the given keys.
+type byKey struct {
+	crits []parser.CriteriaAST
+	keys  []string
+}
+
+func (b byKey) Len() int {
+	return len(b.crits)
+}
+
+func (b byKey) Less(i, j int) bool {
+	return b.keys[i] < b.keys[j]
+}
+
+func (b byKey) Swap(i, j int) {
+	b.crits[i], b.crits[j] = b.crits[j], b.crits[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalCriteria(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Criteria
+	}{
+		{
+			name: "reordered or",
+			a:    Criteria{From: "{a@b.com c@d.com}"},
+			b:    Criteria{From: "{c@d.com a@b.com}"},
+		},
+		{
+			name: "reordered query",
+			a:    Criteria{Query: "{foo bar}"},
+			b:    Criteria{Query: "{bar foo}"},
+		},
+		{
+			name: "negated operator args",
+			a:    Criteria{Query: "-from:(a b)"},
+			b:    Criteria{Query: "-from:(b a)"},
+		},
+		{
+			name: "nested",
+			a:    Criteria{Query: "{(a b) (c d)}"},
+			b:    Criteria{Query: "{(d c) (b a)}"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, canonicalCriteria(tc.a), canonicalCriteria(tc.b))
+		})
+	}
+}
+
+func TestCanonicalCriteriaDifferent(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Criteria
+	}{
+		{
+			name: "and vs or",
+			a:    Criteria{Query: "{foo bar}"},
+			b:    Criteria{Query: "(foo bar)"},
+		},
+		{
+			name: "different fields",
+			a:    Criteria{From: "a@b.com"},
+			b:    Criteria{To: "a@b.com"},
+		},
+		{
+			name: "negation",
+			a:    Criteria{Query: "-foo bar"},
+			b:    Criteria{Query: "foo -bar"},
+		},
+		{
+			name: "quoted phrase",
+			a:    Criteria{Subject: `"foo bar"`},
+			b:    Criteria{Subject: "foo bar"},
+		},
+		{
+			name: "negated group",
+			a:    Criteria{Query: "-(from:a to:b)"},
+			b:    Criteria{From: "a", To: "b"},
+		},
+		{
+			name: "negated operator",
+			a:    Criteria{Query: "-from:(a b)"},
+			b:    Criteria{Query: "from:(a b)"},
+		},
+		{
+			name: "negated or",
+			a:    Criteria{Query: "-{from:a to:b}"},
+			b:    Criteria{Query: "{from:a to:b}"},
+		},
+		{
+			name: "exact words",
+			a:    Criteria{Query: `"a" "b"`},
+			b:    Criteria{Query: "(a b)"},
+		},
+		{
+			name: "quoted negation",
+			a:    Criteria{Query: `"-from:a"`},
+			b:    Criteria{Query: "-from:a"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NotEqual(t, canonicalCriteria(tc.a), canonicalCriteria(tc.b))
+		})
+	}
+}
+
+func TestCanonicalCriteriaUnsupported(t *testing.T) {
+	// Unsupported queries are kept as they are.
+	c := Criteria{Query: "foo AROUND 3 bar"}
+	assert.Equal(t, c, canonicalCriteria(c))
+	assert.Equal(t, Criteria{}, canonicalCriteria(Criteria{}))
+}
+
+func TestCanonicalCriteriaInexact(t *testing.T) {
+	// Criteria not generated back exactly as they are written are kept as
+	// they are, as their meaning might not be understood.
+	tests := []Criteria{
+		{Subject: `"hello"`},
+		{Query: `"a" "b"`},
+		{Query: `"-from:a"`},
+		{Query: "bar OR foo"},
+		{Query: " -{b a} list:foo"},
+		{Query: "from:a@b.com foo"},
+	}
+	for _, c := range tests {
+		assert.Equal(t, c, canonicalCriteria(c))
+	}
+}
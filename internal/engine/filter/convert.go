@@ -8,13 +8,15 @@ import (
 	"github.com/mbrt/gmailctl/internal/engine/parser"
 )
 
+// DefaultSizeLimit is the maximum size of a generated filter.
+//
 // There's no documented limit on filter size on Gmail, but this educated guess
 // is better than nothing.
-const defaultSizeLimit = 20
+const DefaultSizeLimit = 20
 
 // FromRules translates rules into entries that map directly into Gmail filters.
 func FromRules(rs []parser.Rule) (Filters, error) {
-	return FromRulesWithLimit(rs, defaultSizeLimit)
+	return FromRulesWithLimit(rs, DefaultSizeLimit)
 }
 
 // FromRulesWithLimit translates rules into entries that map directly into
@@ -24,7 +26,7 @@ func FromRulesWithLimit(rs []parser.Rule, sizeLimit int) (Filters, error) {
 	for i, rule := range rs {
 		filters, err := FromRule(rule, sizeLimit)
 		if err != nil {
-			return res, fmt.Errorf("generating rule #%d: %w", i, err)
+			return res, fmt.Errorf("generating %s: %w", parser.RuleName(i, rule.Source), err)
 		}
 		res = append(res, filters...)
 	}
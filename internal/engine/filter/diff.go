@@ -8,49 +8,151 @@ import (
 
 	"github.com/pmezard/go-difflib/difflib"
 
-	"github.com/mbrt/gmailctl/internal/graph"
 	"github.com/mbrt/gmailctl/internal/reporting"
 )
 
+// DiffOptions controls how the diff between filters is computed and printed.
+type DiffOptions struct {
+	// Unmanaged reports the upstream filters not managed by the local
+	// config. If nil, all of them are managed.
+	Unmanaged func(Filter) bool
+	// DebugInfo adds debugging information to the printed filters.
+	DebugInfo bool
+	// ContextLines is the number of unchanged lines printed around the
+	// changes.
+	ContextLines int
+	// Colorize prints the diff with colors.
+	Colorize bool
+}
+
 // Diff computes the diff between two lists of filters.
 //
 // To compute the diff, IDs are ignored, only the contents of the filters are actually considered.
-func Diff(upstream, local Filters, debugInfo bool, contextLines int, colorize bool) (FiltersDiff, error) {
+//
+// Upstream filters matched by opts.Unmanaged (if not nil) are not managed by
+// the local config, so they are never removed: they are reported as ignored
+// instead.
+func Diff(upstream, local Filters, opts DiffOptions) (FiltersDiff, error) {
 	// Computing the diff is very expensive, so we have to minimize the number of filters
 	// we have to analyze. To do so, we get rid of the filters that are exactly the same,
 	// by hashing them.
 	added, removed := changedFilters(upstream, local)
-	return NewMinimalFiltersDiff(added, removed, debugInfo, contextLines, colorize), nil
+	var ignored Filters
+	if opts.Unmanaged != nil {
+		var managed Filters
+		for _, f := range removed {
+			if opts.Unmanaged(f) {
+				ignored = append(ignored, f)
+			} else {
+				managed = append(managed, f)
+			}
+		}
+		removed = managed
+	}
+	res := NewMinimalFiltersDiff(added, removed, opts.DebugInfo, opts.ContextLines, opts.Colorize)
+	res.Ignored = ignored
+	return res, nil
 }
 
 // NewMinimalFiltersDiff creates a new FiltersDiff with reordered filters, where
-// similar added and removed ones are next to each other.
+// similar added and removed ones are next to each other. Pairs of added and
+// removed filters that are similar enough are reported as modified.
 //
-// The algorithm used is a quadratic approximation to the otherwise NP-complete
-// travel salesman problem. Hopefully the number of filters is low enough to
-// make this not too slow and the approximation not too bad.
+// Filters are paired by solving an assignment problem on their similarity,
+// split into independent subproblems to scale to large numbers of filters.
 func NewMinimalFiltersDiff(added, removed Filters, printDebugInfo bool, contextLines int, colorize bool) FiltersDiff {
+	var modified []ModifiedFilter
 	if len(added) > 0 && len(removed) > 0 {
-		added, removed = reorderWithHungarian(added, removed)
+		added, removed, modified = reorderBySimilarity(added, removed)
+	}
+	return FiltersDiff{
+		Added:          added,
+		Removed:        removed,
+		Modified:       modified,
+		PrintDebugInfo: printDebugInfo,
+		ContextLines:   contextLines,
+		Colorize:       colorize,
 	}
-	return FiltersDiff{added, removed, printDebugInfo, contextLines, colorize}
 }
 
 // FiltersDiff contains filters that have been added and removed locally with respect to upstream.
 type FiltersDiff struct {
-	Added          Filters
-	Removed        Filters
+	Added   Filters
+	Removed Filters
+	// Modified contains pairs of similar removed and added filters. These
+	// are not repeated in Added and Removed. Gmail filters are immutable,
+	// so a modified filter is applied by deleting the old filter and
+	// creating the new one.
+	Modified []ModifiedFilter
+	// Ignored contains the upstream filters not managed by the local
+	// config, which would otherwise be removed. They are left untouched.
+	Ignored        Filters
 	PrintDebugInfo bool
 	ContextLines   int
 	Colorize       bool
 }
 
+// ModifiedFilter is a filter replaced by a similar one.
+//
+// Gmail doesn't support updating filters, so applying the change means
+// removing the old filter and creating the new one.
+type ModifiedFilter struct {
+	Old Filter
+	New Filter
+}
+
 // Empty returns true if the diff is empty.
 func (f FiltersDiff) Empty() bool {
-	return len(f.Added) == 0 && len(f.Removed) == 0
+	return len(f.Added) == 0 && len(f.Removed) == 0 && len(f.Modified) == 0
+}
+
+// Summary returns the number of changes in the diff, by kind.
+func (f FiltersDiff) Summary() string {
+	res := fmt.Sprintf("%d added, %d modified, %d removed", len(f.Added), len(f.Modified), len(f.Removed))
+	if len(f.Ignored) > 0 {
+		res += fmt.Sprintf(" (%d ignored)", len(f.Ignored))
+	}
+	return res
+}
+
+// AllAdded returns all the filters to create, including the new version of
+// the modified ones.
+func (f FiltersDiff) AllAdded() Filters {
+	res := append(Filters{}, f.Added...)
+	for _, m := range f.Modified {
+		res = append(res, m.New)
+	}
+	return res
+}
+
+// AllRemoved returns all the filters to delete, including the old version of
+// the modified ones.
+func (f FiltersDiff) AllRemoved() Filters {
+	res := append(Filters{}, f.Removed...)
+	for _, m := range f.Modified {
+		res = append(res, m.Old)
+	}
+	return res
 }
 
 func (f FiltersDiff) String() string {
+	s := f.addedRemovedString()
+	if s == "" && (len(f.Modified) > 0 || len(f.Ignored) > 0) {
+		s = "--- Current\n+++ TO BE APPLIED\n"
+	}
+	for _, m := range f.Modified {
+		s += f.modifiedString(m)
+	}
+	for _, i := range f.Ignored {
+		s += f.ignoredString(i)
+	}
+	if f.Colorize {
+		s = reporting.ColorizeDiff(s)
+	}
+	return s
+}
+
+func (f FiltersDiff) addedRemovedString() string {
 	var removed, added string
 	if f.PrintDebugInfo {
 		removed = f.Removed.DebugString()
@@ -71,11 +173,120 @@ func (f FiltersDiff) String() string {
 		// We can't get a diff apparently, let's make something up here
 		return fmt.Sprintf("Removed:\n%s\nAdded:\n%s", removed, added)
 	}
-	if f.Colorize {
-		s = reporting.ColorizeDiff(s)
+	return s
+}
+
+// modifiedString renders the modified filter section by section: criteria
+// and actions are compared separately.
+func (f FiltersDiff) modifiedString(m ModifiedFilter) string {
+	w := writer{}
+	w.WriteString("@@ modified filter @@\n")
+	if f.PrintDebugInfo {
+		writeLinesDiff(&w, debugLines(m.Old), debugLines(m.New), f.ContextLines)
 	}
+	oldCrit, oldActions := filterSections(m.Old)
+	newCrit, newActions := filterSections(m.New)
+	w.WriteString(" * Criteria:\n")
+	writeLinesDiff(&w, oldCrit, newCrit, f.ContextLines)
+	w.WriteString("   Actions:\n")
+	writeLinesDiff(&w, oldActions, newActions, f.ContextLines)
+	return w.String()
+}
 
-	return s
+// ignoredString renders an unmanaged filter, left untouched.
+func (f FiltersDiff) ignoredString(i Filter) string {
+	w := writer{}
+	w.WriteString("@@ ignored filter (unmanaged) @@\n")
+	if f.PrintDebugInfo {
+		writePrefixed(&w, " ", debugLines(i))
+	}
+	crit, actions := filterSections(i)
+	w.WriteString(" * Criteria:\n")
+	writePrefixed(&w, " ", crit)
+	w.WriteString("   Actions:\n")
+	writePrefixed(&w, " ", actions)
+	return w.String()
+}
+
+// writeLinesDiff writes the diff between the two lists of lines. Unchanged
+// lines farther than contextLines from a change are elided.
+func writeLinesDiff(w *writer, a, b []string, contextLines int) {
+	ops := difflib.NewMatcher(a, b).GetOpCodes()
+	for i, op := range ops {
+		switch op.Tag {
+		case 'e':
+			writeContext(w, a[op.I1:op.I2], contextLines, i > 0, i < len(ops)-1)
+		case 'd':
+			writePrefixed(w, "-", a[op.I1:op.I2])
+		case 'i':
+			writePrefixed(w, "+", b[op.J1:op.J2])
+		case 'r':
+			writePrefixed(w, "-", a[op.I1:op.I2])
+			writePrefixed(w, "+", b[op.J1:op.J2])
+		}
+	}
+}
+
+// writeContext writes unchanged lines, keeping only the ones close to the
+// changes before and after them. If there are no changes at all, the first
+// lines are kept.
+func writeContext(w *writer, lines []string, n int, changeBefore, changeAfter bool) {
+	head, tail := n, n
+	switch {
+	case !changeBefore && !changeAfter:
+		tail = 0
+	case !changeBefore:
+		head = 0
+	case !changeAfter:
+		tail = 0
+	}
+	if head+tail >= len(lines) {
+		writePrefixed(w, " ", lines)
+		return
+	}
+	writePrefixed(w, " ", lines[:head])
+	w.WriteString("     ...\n")
+	writePrefixed(w, " ", lines[len(lines)-tail:])
+}
+
+func writePrefixed(w *writer, prefix string, lines []string) {
+	for _, l := range lines {
+		w.WriteString(prefix + l)
+	}
+}
+
+// filterSections splits the lines of the filter representation into the
+// ones describing the criteria and the ones describing the actions. The
+// section headers are excluded.
+func filterSections(f Filter) (criteria, actions []string) {
+	lines := difflib.SplitLines(f.String())
+	inActions := false
+	for _, l := range lines {
+		switch {
+		case l == "* Criteria:\n" || l == "\n":
+			continue
+		case l == "  Actions:\n":
+			inActions = true
+		case inActions:
+			actions = append(actions, l)
+		default:
+			criteria = append(criteria, l)
+		}
+	}
+	return criteria, actions
+}
+
+// debugLines returns the extra lines of the debug representation of the
+// filter.
+func debugLines(f Filter) []string {
+	lines := difflib.SplitLines(f.DebugString())
+	var res []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, "# ") {
+			res = append(res, l)
+		}
+	}
+	return res
 }
 
 func changedFilters(upstream, local Filters) (added, removed Filters) {
@@ -156,11 +367,18 @@ func newHashedFilters(fs Filters) hashedFilters {
 	return res
 }
 
+// Equivalent returns true if the two filters have the same actions and
+// criteria, even if written differently. IDs are ignored.
+func Equivalent(f1, f2 Filter) bool {
+	return hashFilter(f1).hash == hashFilter(f2).hash
+}
+
 func hashFilter(f Filter) hashedFilter {
-	// We have to hash only the contents, not the ID
+	// We have to hash only the contents, not the ID. Criteria are
+	// canonicalized, to ignore differences in how they are written.
 	noIDFilter := Filter{
 		Action:   f.Action,
-		Criteria: f.Criteria,
+		Criteria: canonicalCriteria(f.Criteria),
 	}
 	h := hashStruct(noIDFilter)
 	return hashedFilter{h, f}
@@ -175,89 +393,51 @@ func hashStruct(a interface{}) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-// reorderWithHungarian reorders the two lists to make them look as similar as
-// possible based on the hungarian algorithm.
-//
-// See https://en.wikipedia.org/wiki/Hungarian_algorithm
-func reorderWithHungarian(f1, f2 Filters) (Filters, Filters) {
-	c := costMatrix(f1, f2)
-	mapping := hungarian(c)
-	return reorderWithMapping(f1, f2, mapping)
+// reorderBySimilarity reorders the two lists to make them look as similar as
+// possible. Matched filters that are similar enough are returned as modified.
+func reorderBySimilarity(added, removed Filters) (Filters, Filters, []ModifiedFilter) {
+	return reorderWithMapping(added, removed, matchFilters(added, removed))
 }
 
-func costMatrix(fs1, fs2 Filters) [][]float64 {
-	// Compute the strings only once at the beginning
-	ss1 := filterStrings(fs1)
-	ss2 := filterStrings(fs2)
-
-	var c [][]float64
-	for i, s1 := range ss1 {
-		c = append(c, nil)
-		for _, s2 := range ss2 {
-			c[i] = append(c[i], diffCost(s1, s2))
-		}
-	}
+// minModifiedSimilarity is the minimum similarity between two filters, for
+// them to be considered a modification of each other.
+const minModifiedSimilarity = 0.5
 
-	return c
-}
-
-type filterLines []string
-
-func filterStrings(fs Filters) []filterLines {
-	var res []filterLines
-	for _, f := range fs {
-		res = append(res, difflib.SplitLines(f.String()))
-	}
-	return res
-}
-
-func diffCost(s1, s2 filterLines) float64 {
-	m := difflib.NewMatcher(s1, s2)
-	// Ratio returns a measure of similarity between 0 and 1.
-	// We have to return a cost instead.
-	return 1 - m.Ratio()
-}
-
-func hungarian(c [][]float64) []int {
-	if len(c) == 0 {
-		return nil
-	}
-
-	var mnk graph.Munkres
-	mnk.Init(len(c), len(c[0]))
-	mnk.SetCostMatrix(c)
-	mnk.Run()
-	return mnk.Links
-}
-
-func reorderWithMapping(f1, f2 Filters, mapping []int) (Filters, Filters) {
-	var r1, r2 Filters
+func reorderWithMapping(added, removed Filters, mapping []int) (Filters, Filters, []ModifiedFilter) {
+	var (
+		r1, r2   Filters
+		modified []ModifiedFilter
+	)
 
 	mappedF1 := map[int]struct{}{}
 	mappedF2 := map[int]struct{}{}
 
-	// mapping[i] = j means that filter1[i] is matched with filter2[j]
+	// mapping[i] = j means that added[i] is matched with removed[j]
 	for i, j := range mapping {
 		if j < 0 {
 			continue
 		}
-		r1 = append(r1, f1[i])
-		r2 = append(r2, f2[j])
 		mappedF1[i] = struct{}{}
 		mappedF2[j] = struct{}{}
+		if similarity(added[i], removed[j]) >= minModifiedSimilarity {
+			modified = append(modified, ModifiedFilter{Old: removed[j], New: added[i]})
+			continue
+		}
+		r1 = append(r1, added[i])
+		r2 = append(r2, removed[j])
 	}
 
 	// Add unmapped filters
-	for i, f := range f1 {
+	for i, f := range added {
 		if _, ok := mappedF1[i]; !ok {
 			r1 = append(r1, f)
 		}
 	}
-	for i, f := range f2 {
+	for i, f := range removed {
 		if _, ok := mappedF2[i]; !ok {
 			r2 = append(r2, f)
 		}
 	}
 
-	return r1, r2
+	return r1, r2, modified
 }
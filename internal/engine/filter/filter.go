@@ -72,16 +72,11 @@ func (f Filter) String() string {
 	w.WriteParam("query", indent(f.Criteria.Query, 2))
 
 	w.WriteString("  Actions:\n")
-	w.WriteBool("archive", f.Action.Archive)
-	w.WriteBool("delete", f.Action.Delete)
-	w.WriteBool("mark as important", f.Action.MarkImportant)
-	w.WriteBool("never mark as important", f.Action.MarkNotImportant)
-	w.WriteBool("never mark as spam", f.Action.MarkNotSpam)
-	w.WriteBool("mark as read", f.Action.MarkRead)
-	w.WriteBool("star", f.Action.Star)
-	w.WriteParam("categorize as", string(f.Action.Category))
-	w.WriteParam("apply label", f.Action.AddLabel)
-	w.WriteParam("forward to", f.Action.Forward)
+	for _, a := range f.Action.Fields() {
+		w.WriteString("    ")
+		w.WriteString(a)
+		w.WriteRune('\n')
+	}
 
 	return w.String()
 }
@@ -210,6 +205,35 @@ func (a Actions) Empty() bool {
 	return a == Actions{}
 }
 
+// Fields returns the descriptions of the actions, as they appear in the text
+// representation of the filter (e.g. "apply label: foo").
+func (a Actions) Fields() []string {
+	var res []string
+	addBool := func(name string, value bool) {
+		if value {
+			res = append(res, name)
+		}
+	}
+	addParam := func(name, value string) {
+		if value != "" {
+			res = append(res, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+
+	addBool("archive", a.Archive)
+	addBool("delete", a.Delete)
+	addBool("mark as important", a.MarkImportant)
+	addBool("never mark as important", a.MarkNotImportant)
+	addBool("never mark as spam", a.MarkNotSpam)
+	addBool("mark as read", a.MarkRead)
+	addBool("star", a.Star)
+	addParam("categorize as", string(a.Category))
+	addParam("apply label", a.AddLabel)
+	addParam("forward to", a.Forward)
+
+	return res
+}
+
 // Criteria represents the filtering criteria associated with a Gmail filter.
 type Criteria struct {
 	From    string
@@ -223,6 +247,24 @@ func (c Criteria) Empty() bool {
 	return c == Criteria{}
 }
 
+// Fields returns the descriptions of the criteria, as they appear in the text
+// representation of the filter (e.g. "from: foo@bar.com"), but without
+// indenting the query.
+func (c Criteria) Fields() []string {
+	var res []string
+	for _, p := range [][2]string{
+		{"from", c.From},
+		{"to", c.To},
+		{"subject", c.Subject},
+		{"query", c.Query},
+	} {
+		if p[1] != "" {
+			res = append(res, fmt.Sprintf("%s: %s", p[0], p[1]))
+		}
+	}
+	return res
+}
+
 // ToGmailSearch returns the equivalent query in Gmail search syntax.
 func (c Criteria) ToGmailSearch() string {
 	var res []string
@@ -266,15 +308,6 @@ func (w *writer) WriteParam(name, value string) {
 	w.WriteRune('\n')
 }
 
-func (w *writer) WriteBool(name string, value bool) {
-	if !value {
-		return
-	}
-	w.WriteString("    ")
-	w.WriteString(name)
-	w.WriteRune('\n')
-}
-
 func (w *writer) WriteString(a string) {
 	if w.err != nil {
 		return
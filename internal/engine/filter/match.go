@@ -0,0 +1,245 @@
+package filter
+
+import (
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/graph"
+)
+
+// Criteria lines shared by more than this number of filters are too generic
+// to tell which filters are related (e.g. a common 'has:attachment').
+const maxKeyFilters = 8
+
+// lineSet is the set of lines describing criteria and actions of a filter.
+type lineSet map[string]struct{}
+
+func newLineSet(f Filter) lineSet {
+	criteria, actions := filterSections(f)
+	res := lineSet{}
+	for _, l := range criteria {
+		res[l] = struct{}{}
+	}
+	for _, l := range actions {
+		res[l] = struct{}{}
+	}
+	return res
+}
+
+// lineSimilarity returns the fraction of lines in common between the two
+// sets (i.e. the Dice coefficient), from 0 to 1.
+func lineSimilarity(common, len1, len2 int) float64 {
+	if len1+len2 == 0 {
+		return 1
+	}
+	return 2 * float64(common) / float64(len1+len2)
+}
+
+// similarity returns a measure of how similar two filters are, between 0 and
+// 1. Only the criteria and actions are compared, not the section headers, as
+// they are the same in all the filters.
+func similarity(f1, f2 Filter) float64 {
+	return lineSetSimilarity(newLineSet(f1), newLineSet(f2))
+}
+
+func lineSetSimilarity(s1, s2 lineSet) float64 {
+	common := 0
+	for l := range s1 {
+		if _, ok := s2[l]; ok {
+			common++
+		}
+	}
+	return lineSimilarity(common, len(s1), len(s2))
+}
+
+// matchFilters pairs added and removed filters, maximising their total
+// similarity. mapping[i] = j means that added[i] is matched with removed[j],
+// while -1 means no match.
+//
+// Computing the similarity of all the pairs and solving a single assignment
+// problem doesn't scale to large accounts, so the problem is split instead:
+// filters are first matched within groups connected by common criteria.
+// Only the criteria specific to some filters are used for grouping: actions
+// (e.g. archive), generic lines and terms shared by many filters would
+// connect everything together.
+// Only the filters left over are then matched together, so that a filter
+// with new criteria but the same actions is still found as modified.
+func matchFilters(added, removed Filters) []int {
+	addedLines := make([]lineSet, len(added))
+	for i, f := range added {
+		addedLines[i] = newLineSet(f)
+	}
+	removedLines := make([]lineSet, len(removed))
+	for j, f := range removed {
+		removedLines[j] = newLineSet(f)
+	}
+
+	mapping := make([]int, len(added))
+	for i := range mapping {
+		mapping[i] = -1
+	}
+	matched := make([]bool, len(removed))
+	assign := func(c component) {
+		if len(c.added) == 0 || len(c.removed) == 0 {
+			return
+		}
+		cost := graph.Alloc(len(c.added), len(c.removed))
+		for ci, i := range c.added {
+			for cj, j := range c.removed {
+				cost[ci][cj] = 1 - lineSetSimilarity(addedLines[i], removedLines[j])
+			}
+		}
+		for ci, cj := range graph.MinCostAssignment(cost) {
+			if cj < 0 || cost[ci][cj] >= 1 {
+				// Filters with nothing in common are not a match.
+				continue
+			}
+			i, j := c.added[ci], c.removed[cj]
+			mapping[i] = j
+			matched[j] = true
+		}
+	}
+
+	for _, c := range criteriaComponents(added, removed) {
+		assign(c)
+	}
+
+	var rest component
+	for i, j := range mapping {
+		if j < 0 {
+			rest.added = append(rest.added, i)
+		}
+	}
+	for j, m := range matched {
+		if !m {
+			rest.removed = append(rest.removed, j)
+		}
+	}
+	assign(rest)
+
+	return mapping
+}
+
+// component is a group of added and removed filters, by index.
+type component struct{ added, removed []int }
+
+// criteriaComponents groups together the added and removed filters that are
+// connected by common criteria. Only groups containing both added and
+// removed filters are returned.
+func criteriaComponents(added, removed Filters) []component {
+	addedKeys := make([][]string, len(added))
+	for i, f := range added {
+		addedKeys[i] = criteriaKeys(f)
+	}
+	removedIndex := map[string][]int{}
+	for j, f := range removed {
+		for _, k := range criteriaKeys(f) {
+			removedIndex[k] = append(removedIndex[k], j)
+		}
+	}
+	addedCount := map[string]int{}
+	for _, keys := range addedKeys {
+		for _, k := range keys {
+			addedCount[k]++
+		}
+	}
+
+	// Nodes of the graph are the added filters, followed by the removed
+	// ones.
+	uf := newUnionFind(len(added) + len(removed))
+	for i, keys := range addedKeys {
+		for _, k := range keys {
+			if addedCount[k]+len(removedIndex[k]) > maxKeyFilters {
+				continue
+			}
+			for _, j := range removedIndex[k] {
+				uf.union(i, len(added)+j)
+			}
+		}
+	}
+
+	components := map[int]*component{}
+	var roots []int
+	getComponent := func(node int) *component {
+		r := uf.find(node)
+		c, ok := components[r]
+		if !ok {
+			c = &component{}
+			components[r] = c
+			roots = append(roots, r)
+		}
+		return c
+	}
+	for i := range added {
+		c := getComponent(i)
+		c.added = append(c.added, i)
+	}
+	for j := range removed {
+		c := getComponent(len(added) + j)
+		c.removed = append(c.removed, j)
+	}
+
+	var res []component
+	for _, r := range roots {
+		if c := components[r]; len(c.added) > 0 && len(c.removed) > 0 {
+			res = append(res, *c)
+		}
+	}
+	return res
+}
+
+// criteriaKeys returns the criteria lines of the filter, each one together
+// with the field it belongs to (e.g. 'query: list:foo'). Lines without a
+// value of their own, such as the 'query:' header or the brackets of a
+// group, are left out, as they are shared by unrelated filters.
+func criteriaKeys(f Filter) []string {
+	criteria, _ := filterSections(f)
+	var (
+		res   []string
+		field string
+	)
+	for _, l := range criteria {
+		t := strings.TrimSpace(l)
+		if name, value, ok := strings.Cut(t, ":"); ok && strings.HasPrefix(l, "    ") && !strings.HasPrefix(l, "     ") {
+			// A top level field: e.g. 'from: a@b.com' or 'query:'.
+			field = name
+			if strings.TrimSpace(value) != "" {
+				res = append(res, t)
+			}
+			continue
+		}
+		// Nested lines of a multi-line value. Openers like 'subject:(' or
+		// '-{' and the closing brackets carry no value.
+		v := strings.TrimRight(strings.TrimLeft(t, "-"), "({")
+		if strings.Trim(v, "(){}") == "" || strings.HasSuffix(v, ":") {
+			continue
+		}
+		res = append(res, field+": "+v)
+	}
+	return res
+}
+
+// unionFind is a disjoint-set data structure.
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) unionFind {
+	res := unionFind{parent: make([]int, n)}
+	for i := range res.parent {
+		res.parent[i] = i
+	}
+	return res
+}
+
+func (u unionFind) find(x int) int {
+	for u.parent[x] != x {
+		// Path halving.
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+func (u unionFind) union(x, y int) {
+	u.parent[u.find(x)] = u.find(y)
+}
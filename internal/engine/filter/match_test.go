@@ -0,0 +1,174 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchFilters(t *testing.T) {
+	added := Filters{
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{AddLabel: "work", Star: true},
+		},
+		{
+			Criteria: Criteria{Query: "something else"},
+			Action:   Actions{Forward: "x@y.com"},
+		},
+		{
+			Criteria: Criteria{To: "me@b.com"},
+			Action:   Actions{Archive: true},
+		},
+	}
+	removed := Filters{
+		{
+			Criteria: Criteria{To: "me@b.com"},
+			Action:   Actions{MarkRead: true},
+		},
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{AddLabel: "work"},
+		},
+		{
+			Criteria: Criteria{From: "c@d.com"},
+			Action:   Actions{Delete: true},
+		},
+	}
+
+	// The second added filter has nothing in common with the others, so
+	// it's left unmatched, as the third removed one.
+	assert.Equal(t, []int{1, -1, 0}, matchFilters(added, removed))
+}
+
+func TestMatchFiltersBest(t *testing.T) {
+	added := Filters{
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true},
+		},
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true, MarkRead: true, AddLabel: "foo"},
+		},
+	}
+	removed := Filters{
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true, MarkRead: true, AddLabel: "bar"},
+		},
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true, Star: true},
+		},
+	}
+
+	// Greedily matching the first added filter would give a worse result.
+	assert.Equal(t, []int{1, 0}, matchFilters(added, removed))
+}
+
+func TestSimilarity(t *testing.T) {
+	f1 := Filter{
+		Criteria: Criteria{From: "a@b.com"},
+		Action:   Actions{Archive: true},
+	}
+	f2 := Filter{
+		Criteria: Criteria{From: "a@b.com"},
+		Action:   Actions{MarkRead: true},
+	}
+	assert.Equal(t, 1.0, similarity(f1, f1))
+	assert.Equal(t, 0.5, similarity(f1, f2))
+	assert.Equal(t, 0.0, similarity(f1, Filter{Criteria: Criteria{To: "x"}}))
+}
+
+func TestCriteriaComponents(t *testing.T) {
+	added := Filters{
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true, MarkRead: true},
+		},
+		{
+			Criteria: Criteria{From: "c@d.com"},
+			Action:   Actions{Archive: true, MarkRead: true},
+		},
+	}
+	removed := Filters{
+		{
+			Criteria: Criteria{From: "c@d.com"},
+			Action:   Actions{Archive: true},
+		},
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true, Star: true},
+		},
+		{
+			Criteria: Criteria{From: "e@f.com"},
+			Action:   Actions{Archive: true, MarkRead: true},
+		},
+	}
+
+	// Sharing the actions is not enough to be in the same component.
+	assert.Equal(t, []component{
+		{added: []int{0}, removed: []int{1}},
+		{added: []int{1}, removed: []int{0}},
+	}, criteriaComponents(added, removed))
+}
+
+func TestCriteriaComponentsGenericLines(t *testing.T) {
+	var added, removed Filters
+	for i := 0; i < 5; i++ {
+		// The 'query:' header, the brackets and the common term are shared
+		// by all the filters.
+		f := Filter{
+			Criteria: Criteria{
+				From:  fmt.Sprintf("user%d@b.com", i),
+				Query: "-{has:attachment}",
+			},
+			Action: Actions{Archive: true},
+		}
+		removed = append(removed, f)
+		f.Action.MarkRead = true
+		added = append(added, f)
+	}
+
+	var expected []component
+	for i := 0; i < 5; i++ {
+		expected = append(expected, component{added: []int{i}, removed: []int{i}})
+	}
+	assert.Equal(t, expected, criteriaComponents(added, removed))
+}
+
+func TestCriteriaKeys(t *testing.T) {
+	f := Filter{
+		Criteria: Criteria{
+			From:  "{a@b.com c@d.com}",
+			Query: "list:foo -subject:(x y)",
+		},
+	}
+	assert.Equal(t, []string{
+		"from: {a@b.com c@d.com}",
+		"query: list:foo",
+		"query: x",
+		"query: y",
+	}, criteriaKeys(f))
+}
+
+func TestMatchFiltersSameActions(t *testing.T) {
+	added := Filters{
+		{
+			Criteria: Criteria{From: "a@b.com"},
+			Action:   Actions{Archive: true, MarkRead: true, AddLabel: "work"},
+		},
+	}
+	removed := Filters{
+		{
+			Criteria: Criteria{From: "c@d.com"},
+			Action:   Actions{Archive: true, MarkRead: true, AddLabel: "work"},
+		},
+	}
+
+	// Filters left over after matching by criteria are still paired by
+	// their actions.
+	assert.Equal(t, []int{0}, matchFilters(added, removed))
+}
@@ -0,0 +1,115 @@
+// Package journal records the changes made to Gmail, so that they can be
+// reviewed and undone.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// Kind is the kind of an operation.
+type Kind string
+
+// Kinds of operations.
+const (
+	CreateFilter Kind = "createFilter"
+	DeleteFilter Kind = "deleteFilter"
+	CreateLabel  Kind = "createLabel"
+	UpdateLabel  Kind = "updateLabel"
+	DeleteLabel  Kind = "deleteLabel"
+)
+
+// Operation is a single change made to Gmail.
+type Operation struct {
+	Kind Kind `json:"kind"`
+	// ID is the Gmail ID of the changed object. For created objects, this
+	// is the ID assigned by Gmail.
+	ID string `json:"id,omitempty"`
+	// The object before and after the operation. Before is nil for
+	// created objects and After is nil for deleted ones.
+	FilterBefore *filter.Filter `json:"filterBefore,omitempty"`
+	FilterAfter  *filter.Filter `json:"filterAfter,omitempty"`
+	LabelBefore  *label.Label   `json:"labelBefore,omitempty"`
+	LabelAfter   *label.Label   `json:"labelAfter,omitempty"`
+}
+
+// Entry records the operations executed by a single apply.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Command is the command that made the changes (e.g. 'apply').
+	Command    string      `json:"command"`
+	Operations []Operation `json:"operations"`
+	// Error is set if the apply failed. The operations that succeeded are
+	// recorded anyway, up to the first failed one.
+	Error string `json:"error,omitempty"`
+}
+
+// Summary returns the number of operations in the entry, by kind.
+func (e Entry) Summary() string {
+	counts := map[Kind]int{}
+	for _, op := range e.Operations {
+		counts[op.Kind]++
+	}
+	return fmt.Sprintf("filters: %d created, %d deleted; labels: %d created, %d updated, %d deleted",
+		counts[CreateFilter], counts[DeleteFilter],
+		counts[CreateLabel], counts[UpdateLabel], counts[DeleteLabel])
+}
+
+// Append adds the entry at the end of the journal in the given file,
+// creating it if needed.
+func Append(path string, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Read returns all the entries in the journal, from the oldest. A missing
+// journal has no entries.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+
+	var res []Entry
+	s := bufio.NewScanner(f)
+	// Entries with many filters can be long.
+	s.Buffer(nil, 64*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		res = append(res, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading journal: %w", err)
+	}
+	return res, nil
+}
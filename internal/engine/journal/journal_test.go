@@ -0,0 +1,213 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	entries, err := Read(path)
+	require.Nil(t, err)
+	assert.Empty(t, entries)
+
+	e1 := Entry{
+		Time:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Command: "apply",
+		Operations: []Operation{
+			{Kind: CreateFilter, FilterAfter: &filter.Filter{Criteria: filter.Criteria{From: "a@b.com"}}},
+			{Kind: DeleteLabel, ID: "l1", LabelBefore: &label.Label{ID: "l1", Name: "old"}},
+		},
+	}
+	e2 := Entry{
+		Time:    time.Date(2026, 1, 3, 3, 4, 5, 0, time.UTC),
+		Command: "undo #1",
+		Error:   "something failed",
+	}
+	require.Nil(t, Append(path, e1))
+	require.Nil(t, Append(path, e2))
+
+	entries, err = Read(path)
+	require.Nil(t, err)
+	assert.Equal(t, []Entry{e1, e2}, entries)
+	assert.Equal(t, "filters: 1 created, 0 deleted; labels: 0 created, 0 updated, 1 deleted", e1.Summary())
+}
+
+// fakeAPI assigns sequential IDs to the created objects.
+type fakeAPI struct {
+	// failAfter is the number of objects changed before failing, if
+	// positive.
+	failAfter int
+}
+
+func (a fakeAPI) changed(ids []string) ([]string, error) {
+	if a.failAfter > 0 && len(ids) > a.failAfter {
+		return ids[:a.failAfter], errors.New("failed")
+	}
+	return ids, nil
+}
+
+func (a fakeAPI) AddLabels(lbs label.Labels) ([]string, error) {
+	return a.changed(newIDs("l", len(lbs)))
+}
+
+func (a fakeAPI) AddFilters(fs filter.Filters) ([]string, error) {
+	return a.changed(newIDs("f", len(fs)))
+}
+
+func (a fakeAPI) UpdateLabels(lbs label.Labels) ([]string, error) {
+	var ids []string
+	for _, l := range lbs {
+		ids = append(ids, l.ID)
+	}
+	return a.changed(ids)
+}
+
+func (a fakeAPI) DeleteFilters(ids []string) ([]string, error) { return a.changed(ids) }
+func (a fakeAPI) DeleteLabels(ids []string) ([]string, error)  { return a.changed(ids) }
+
+func newIDs(prefix string, n int) []string {
+	var res []string
+	for i := 0; i < n; i++ {
+		res = append(res, fmt.Sprintf("%s-new%d", prefix, i))
+	}
+	return res
+}
+
+func TestRecorder(t *testing.T) {
+	upstream := apply.GmailConfig{
+		Filters: filter.Filters{{ID: "f1", Criteria: filter.Criteria{From: "old@b.com"}}},
+		Labels:  label.Labels{{ID: "l1", Name: "news"}},
+	}
+	added := filter.Filter{Criteria: filter.Criteria{From: "new@b.com"}}
+	updated := label.Label{ID: "l1", Name: "news", Color: &label.Color{Background: "#000000", Text: "#ffffff"}}
+
+	r := NewRecorder(fakeAPI{}, upstream)
+	_, err := r.AddFilters(filter.Filters{added})
+	require.Nil(t, err)
+	_, err = r.UpdateLabels(label.Labels{updated})
+	require.Nil(t, err)
+	_, err = r.DeleteFilters([]string{"f1"})
+	require.Nil(t, err)
+
+	created := added
+	created.ID = "f-new0"
+	assert.Equal(t, []Operation{
+		{Kind: CreateFilter, ID: "f-new0", FilterAfter: &created},
+		{Kind: UpdateLabel, ID: "l1", LabelBefore: &upstream.Labels[0], LabelAfter: &updated},
+		{Kind: DeleteFilter, ID: "f1", FilterBefore: &upstream.Filters[0]},
+	}, r.Operations())
+}
+
+func TestRecorderPartialFailure(t *testing.T) {
+	r := NewRecorder(fakeAPI{failAfter: 1}, apply.GmailConfig{})
+	ids, err := r.AddLabels(label.Labels{{Name: "a"}, {Name: "b"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"l-new0"}, ids)
+
+	// Only the label created before the failure is recorded.
+	assert.Equal(t, []Operation{
+		{Kind: CreateLabel, ID: "l-new0", LabelAfter: &label.Label{ID: "l-new0", Name: "a"}},
+	}, r.Operations())
+}
+
+func TestRevert(t *testing.T) {
+	created := filter.Filter{Criteria: filter.Criteria{From: "new@b.com"}, Action: filter.Actions{Archive: true}}
+	deleted := filter.Filter{ID: "f1", Criteria: filter.Criteria{From: "old@b.com"}, Action: filter.Actions{Star: true}}
+	e := Entry{
+		Operations: []Operation{
+			{Kind: CreateLabel, LabelAfter: &label.Label{Name: "work"}},
+			{Kind: CreateFilter, FilterAfter: &created},
+			{
+				Kind:        UpdateLabel,
+				ID:          "l1",
+				LabelBefore: &label.Label{ID: "l1", Name: "news"},
+				LabelAfter:  &label.Label{ID: "l1", Name: "news", Color: &label.Color{Background: "#000000", Text: "#ffffff"}},
+			},
+			{Kind: DeleteFilter, ID: "f1", FilterBefore: &deleted},
+			{Kind: DeleteLabel, ID: "l2", LabelBefore: &label.Label{ID: "l2", Name: "old"}},
+		},
+	}
+	current := apply.GmailConfig{
+		Filters: filter.Filters{
+			{ID: "f2", Criteria: created.Criteria, Action: created.Action},
+			{ID: "f3", Criteria: filter.Criteria{To: "me"}, Action: filter.Actions{MarkRead: true}},
+		},
+		Labels: label.Labels{
+			{ID: "l1", Name: "news", Color: &label.Color{Background: "#000000", Text: "#ffffff"}},
+			{ID: "l3", Name: "work"},
+		},
+	}
+
+	res := Revert(e, current)
+	assert.Equal(t, filter.Filters{
+		current.Filters[1],
+		{Criteria: deleted.Criteria, Action: deleted.Action},
+	}, res.Filters)
+	assert.Equal(t, label.Labels{
+		{ID: "l1", Name: "news"},
+		{Name: "old"},
+	}, res.Labels)
+}
+
+func TestRevertRename(t *testing.T) {
+	e := Entry{
+		Operations: []Operation{
+			{
+				Kind:        UpdateLabel,
+				ID:          "l1",
+				LabelBefore: &label.Label{ID: "l1", Name: "work"},
+				LabelAfter:  &label.Label{ID: "l1", Name: "job", PreviousNames: []string{"work"}},
+			},
+		},
+	}
+	current := apply.GmailConfig{
+		Filters: filter.Filters{
+			{ID: "f1", Criteria: filter.Criteria{From: "boss@b.com"}, Action: filter.Actions{AddLabel: "job"}},
+		},
+		Labels: label.Labels{{ID: "l1", Name: "job"}},
+	}
+
+	res := Revert(e, current)
+	assert.Equal(t, label.Labels{{ID: "l1", Name: "work", PreviousNames: []string{"job"}}}, res.Labels)
+	assert.Equal(t, "work", res.Filters[0].Action.AddLabel)
+
+	// Undoing renames the label back, leaving the filter untouched.
+	d, err := apply.Diff(res, current, false, apply.DefaultContextLines, false)
+	require.Nil(t, err)
+	assert.Equal(t, "labels: 0 added, 1 modified, 0 removed", d.Summary())
+}
+
+func TestRevertCreatedByID(t *testing.T) {
+	f := filter.Filter{Criteria: filter.Criteria{From: "a@b.com"}, Action: filter.Actions{Archive: true}}
+	created := f
+	created.ID = "f2"
+	e := Entry{
+		Operations: []Operation{
+			{Kind: CreateLabel, ID: "l2", LabelAfter: &label.Label{ID: "l2", Name: "work"}},
+			{Kind: CreateFilter, ID: "f2", FilterAfter: &created},
+		},
+	}
+	// An equivalent filter existed already: only the created one goes.
+	existing := f
+	existing.ID = "f1"
+	current := apply.GmailConfig{
+		Filters: filter.Filters{existing, created},
+		Labels:  label.Labels{{ID: "l2", Name: "work"}},
+	}
+
+	res := Revert(e, current)
+	assert.Equal(t, filter.Filters{existing}, res.Filters)
+	assert.Empty(t, res.Labels)
+}
@@ -0,0 +1,100 @@
+package journal
+
+import (
+	"github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// Recorder wraps the Gmail APIs, recording every object changed
+// successfully, also when a later one fails.
+type Recorder struct {
+	api     apply.API
+	filters map[string]filter.Filter
+	labels  map[string]label.Label
+	ops     []Operation
+}
+
+// NewRecorder returns a recorder wrapping the given API. The upstream
+// config is used to record the objects before they are updated or deleted.
+func NewRecorder(api apply.API, upstream apply.GmailConfig) *Recorder {
+	res := &Recorder{
+		api:     api,
+		filters: map[string]filter.Filter{},
+		labels:  map[string]label.Label{},
+	}
+	for _, f := range upstream.Filters {
+		res.filters[f.ID] = f
+	}
+	for _, l := range upstream.Labels {
+		res.labels[l.ID] = l
+	}
+	return res
+}
+
+// Operations returns the operations recorded so far.
+func (r *Recorder) Operations() []Operation {
+	return r.ops
+}
+
+// AddLabels implements apply.API.
+func (r *Recorder) AddLabels(lbs label.Labels) ([]string, error) {
+	ids, err := r.api.AddLabels(lbs)
+	for i, id := range ids {
+		l := lbs[i]
+		l.ID = id
+		r.ops = append(r.ops, Operation{Kind: CreateLabel, ID: id, LabelAfter: &l})
+	}
+	return ids, err
+}
+
+// AddFilters implements apply.API.
+func (r *Recorder) AddFilters(fs filter.Filters) ([]string, error) {
+	ids, err := r.api.AddFilters(fs)
+	for i, id := range ids {
+		f := fs[i]
+		f.ID = id
+		r.ops = append(r.ops, Operation{Kind: CreateFilter, ID: id, FilterAfter: &f})
+	}
+	return ids, err
+}
+
+// UpdateLabels implements apply.API.
+func (r *Recorder) UpdateLabels(lbs label.Labels) ([]string, error) {
+	ids, err := r.api.UpdateLabels(lbs)
+	for i, id := range ids {
+		l := lbs[i]
+		op := Operation{Kind: UpdateLabel, ID: id, LabelAfter: &l}
+		if before, ok := r.labels[id]; ok {
+			op.LabelBefore = &before
+		}
+		r.ops = append(r.ops, op)
+	}
+	return ids, err
+}
+
+// DeleteFilters implements apply.API.
+func (r *Recorder) DeleteFilters(ids []string) ([]string, error) {
+	deleted, err := r.api.DeleteFilters(ids)
+	for _, id := range deleted {
+		op := Operation{Kind: DeleteFilter, ID: id}
+		if before, ok := r.filters[id]; ok {
+			op.FilterBefore = &before
+		}
+		r.ops = append(r.ops, op)
+	}
+	return deleted, err
+}
+
+// DeleteLabels implements apply.API.
+func (r *Recorder) DeleteLabels(ids []string) ([]string, error) {
+	deleted, err := r.api.DeleteLabels(ids)
+	for _, id := range deleted {
+		op := Operation{Kind: DeleteLabel, ID: id}
+		if before, ok := r.labels[id]; ok {
+			op.LabelBefore = &before
+		}
+		r.ops = append(r.ops, op)
+	}
+	return deleted, err
+}
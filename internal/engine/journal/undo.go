@@ -0,0 +1,99 @@
+package journal
+
+import (
+	"github.com/mbrt/gmailctl/internal/engine/apply"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+)
+
+// Revert returns the given config with the operations of the entry undone:
+// created filters and labels are removed, deleted ones are restored and
+// updated labels get back their previous properties (renamed labels their
+// previous name).
+//
+// Created objects are removed by the ID Gmail assigned to them. Entries
+// recorded without IDs fall back to matching them by content. Objects changed
+// again after the entry are left as they are.
+func Revert(e Entry, current apply.GmailConfig) apply.GmailConfig {
+	res := apply.GmailConfig{
+		Filters: append(filter.Filters{}, current.Filters...),
+		Labels:  append(label.Labels{}, current.Labels...),
+	}
+
+	// Undo in reverse order.
+	for i := len(e.Operations) - 1; i >= 0; i-- {
+		op := e.Operations[i]
+		switch op.Kind {
+		case CreateFilter:
+			res.Filters = removeFilter(res.Filters, op.ID, *op.FilterAfter)
+		case DeleteFilter:
+			if op.FilterBefore != nil {
+				f := *op.FilterBefore
+				f.ID = ""
+				res.Filters = append(res.Filters, f)
+			}
+		case CreateLabel:
+			res.Labels = removeLabel(res.Labels, op.ID, op.LabelAfter.Name)
+		case UpdateLabel:
+			if op.LabelBefore == nil {
+				continue
+			}
+			for j, l := range res.Labels {
+				if l.Name == op.LabelAfter.Name {
+					before := *op.LabelBefore
+					before.ID = l.ID
+					res.Labels[j] = before
+				}
+			}
+			if op.LabelBefore.Name != op.LabelAfter.Name {
+				res = revertRename(res, op.LabelBefore.Name, op.LabelAfter.Name)
+			}
+		case DeleteLabel:
+			if op.LabelBefore != nil {
+				l := *op.LabelBefore
+				l.ID = ""
+				res.Labels = append(res.Labels, l)
+			}
+		}
+	}
+
+	return res
+}
+
+// revertRename gives the label back its old name. The label is renamed
+// instead of being recreated, and the filters using it follow.
+func revertRename(cfg apply.GmailConfig, oldName, newName string) apply.GmailConfig {
+	for i, l := range cfg.Labels {
+		if l.Name == oldName {
+			cfg.Labels[i].PreviousNames = []string{newName}
+		}
+	}
+	for i, f := range cfg.Filters {
+		if f.Action.AddLabel == newName {
+			cfg.Filters[i].Action.AddLabel = oldName
+		}
+	}
+	return cfg
+}
+
+// removeFilter removes the filter with the given ID or, without an ID, the
+// first filter equivalent to the given one.
+func removeFilter(fs filter.Filters, id string, f filter.Filter) filter.Filters {
+	for i, cf := range fs {
+		if (id != "" && cf.ID == id) || (id == "" && filter.Equivalent(cf, f)) {
+			return append(fs[:i:i], fs[i+1:]...)
+		}
+	}
+	return fs
+}
+
+// removeLabel removes the label with the given ID or, without an ID, the
+// one with the given name.
+func removeLabel(ls label.Labels, id, name string) label.Labels {
+	for i, l := range ls {
+		if (id != "" && l.ID == id) || (id == "" && l.Name == name) {
+			return append(ls[:i:i], ls[i+1:]...)
+		}
+	}
+	return ls
+}
@@ -0,0 +1,122 @@
+package label
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// palette contains the colors allowed by Gmail, both for the background and
+// the text of labels.
+//
+// See https://developers.google.com/gmail/api/reference/rest/v1/users.labels
+var palette = []string{
+	"#000000", "#434343", "#666666", "#999999", "#cccccc", "#efefef", "#f3f3f3", "#ffffff",
+	"#fb4c2f", "#ffad47", "#fad165", "#16a766", "#43d692", "#4a86e8", "#a479e2", "#f691b3",
+	"#f6c5be", "#ffe6c7", "#fef1d1", "#b9e4d0", "#c6f3de", "#c9daf8", "#e4d7f5", "#fcdee8",
+	"#efa093", "#ffd6a2", "#fce8b3", "#89d3b2", "#a0eac9", "#a4c2f4", "#d0bcf1", "#fbc8d9",
+	"#e66550", "#ffbc6b", "#fcda83", "#44b984", "#68dfa9", "#6d9eeb", "#b694e8", "#f7a7c0",
+	"#cc3a21", "#eaa041", "#f2c960", "#149e60", "#3dc789", "#3c78d8", "#8e63ce", "#e07798",
+	"#ac2b16", "#cf8933", "#d5ae49", "#0b804b", "#2a9c68", "#285bac", "#653e9b", "#b65775",
+	"#822111", "#a46a21", "#aa8831", "#076239", "#1a764d", "#1c4587", "#41236d", "#83334c",
+	// Legacy colors, still accepted.
+	"#464646", "#e7e7e7", "#0d3472", "#b6cff5", "#0d3b44", "#98d7e4", "#3d188e", "#e3d7ff",
+	"#711a36", "#fbd3e0", "#8a1c0a", "#f2b2a8", "#7a2e0b", "#ffc8af", "#7a4706", "#ffdeb5",
+	"#594c05", "#fbe983", "#684e07", "#fdedc1", "#0b4f30", "#b3efd3", "#04502e", "#a2dcc1",
+	"#c2c2c2", "#4986e7", "#2da2bb", "#b99aff", "#994a64", "#f691b2", "#ff7537", "#ffad46",
+	"#662e37", "#ebdbde", "#cca6ac", "#094228", "#42d692", "#16a765",
+}
+
+// colorNames maps the names of colors to the palette. They follow the
+// color picker in the Gmail UI: one column per hue, from the lightest to the
+// darkest.
+var colorNames = func() map[string]string {
+	hues := []string{"red", "orange", "yellow", "green", "teal", "blue", "purple", "pink"}
+	// Rows of the color picker, after the grays.
+	shades := []struct {
+		suffix string
+		row    int
+	}{
+		{"", 1},
+		{"-lightest", 2},
+		{"-lighter", 3},
+		{"-light", 4},
+		{"-dark", 5},
+		{"-darker", 6},
+		{"-darkest", 7},
+	}
+	res := map[string]string{
+		"black":         "#000000",
+		"gray-darker":   "#434343",
+		"gray-dark":     "#666666",
+		"gray":          "#999999",
+		"gray-light":    "#cccccc",
+		"gray-lighter":  "#efefef",
+		"gray-lightest": "#f3f3f3",
+		"white":         "#ffffff",
+	}
+	for _, s := range shades {
+		for i, h := range hues {
+			res[h+s.suffix] = palette[s.row*len(hues)+i]
+		}
+	}
+	return res
+}()
+
+// resolveColor returns the palette value of named colors, and hex colors in
+// lowercase, as returned by Gmail.
+func resolveColor(c string) string {
+	c = strings.ToLower(c)
+	if hex, ok := colorNames[c]; ok {
+		return hex
+	}
+	return c
+}
+
+// checkColor makes sure that the color is in the palette, suggesting the
+// nearest allowed one if it's not.
+func checkColor(c string) error {
+	for _, p := range palette {
+		if c == p {
+			return nil
+		}
+	}
+	rgb, ok := parseHex(c)
+	if !ok {
+		return fmt.Errorf("invalid color %q: use a color of the Gmail palette in hex (e.g. '#fb4c2f') or a color name (e.g. 'red', 'blue-light')", c)
+	}
+	return fmt.Errorf("color %q is not in the Gmail palette, did you mean %q?", c, nearestColor(rgb))
+}
+
+// nearestColor returns the color of the palette closest to the given one.
+func nearestColor(rgb [3]float64) string {
+	var res string
+	best := math.Inf(1)
+	for _, p := range palette {
+		prgb, _ := parseHex(p)
+		var d float64
+		for i := range rgb {
+			d += (rgb[i] - prgb[i]) * (rgb[i] - prgb[i])
+		}
+		if d < best {
+			res, best = p, d
+		}
+	}
+	return res
+}
+
+func parseHex(c string) ([3]float64, bool) {
+	var res [3]float64
+	if len(c) != 7 || c[0] != '#' {
+		return res, false
+	}
+	for i := range res {
+		v, err := strconv.ParseUint(c[1+2*i:3+2*i], 16, 8)
+		if err != nil {
+			return res, false
+		}
+		res[i] = float64(v)
+	}
+	return res, true
+}
@@ -0,0 +1,56 @@
+package label
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+)
+
+func TestColorNames(t *testing.T) {
+	ls, _ := FromConfig([]v1alpha3.Label{
+		{Name: "a", Color: &v1alpha3.LabelColor{Background: "blue-light", Text: "White"}},
+		{Name: "b", Color: &v1alpha3.LabelColor{Background: "#FB4C2F", Text: "gray-darker"}},
+	})
+	assert.Equal(t, &Color{Background: "#6d9eeb", Text: "#ffffff"}, ls[0].Color)
+	assert.Equal(t, &Color{Background: "#fb4c2f", Text: "#434343"}, ls[1].Color)
+	assert.Nil(t, ls.Validate())
+}
+
+func TestColorNamesInPalette(t *testing.T) {
+	for name, hex := range colorNames {
+		assert.Nil(t, checkColor(hex), name)
+	}
+}
+
+func TestInvalidColor(t *testing.T) {
+	cases := []struct {
+		name  string
+		color Color
+		err   string
+	}{
+		{
+			"near background",
+			Color{Background: "#fb4c2e", Text: "#000000"},
+			`label "x": background: color "#fb4c2e" is not in the Gmail palette, did you mean "#fb4c2f"?`,
+		},
+		{
+			"near text",
+			Color{Background: "#000000", Text: "#0000ff"},
+			`label "x": text: color "#0000ff" is not in the Gmail palette, did you mean "#285bac"?`,
+		},
+		{
+			"unknown name",
+			Color{Background: "magenta", Text: "#000000"},
+			`label "x": background: invalid color "magenta"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Labels{{Name: "x", Color: &tc.color}}.Validate()
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -15,11 +15,20 @@ import (
 //
 // To compute the diff, IDs are ignored, only the properties of the labels are
 // actually considered.
-func Diff(upstream, local Labels, colorize bool) (LabelsDiff, error) {
+//
+// Upstream labels matched by unmanaged (if not nil) are not managed by the
+// local config, so they are never removed: they are reported as ignored
+// instead.
+//
+// Upstream labels named as one of the previous names of a local label are
+// renamed: they are reported as modified, keeping their ID.
+func Diff(upstream, local Labels, unmanaged func(Label) bool, colorize bool) (LabelsDiff, error) {
+	res := LabelsDiff{Colorize: colorize}
+	upstream, local, res.Modified = findRenamed(upstream, local)
+
 	sort.Sort(byName(upstream))
 	sort.Sort(byName(local))
 
-	res := LabelsDiff{Colorize: colorize}
 	i, j := 0, 0
 
 	for i < len(upstream) && j < len(local) {
@@ -30,7 +39,7 @@ func Diff(upstream, local Labels, colorize bool) (LabelsDiff, error) {
 		switch {
 		case cmp < 0:
 			// Local is ahead: it's missing a label
-			res.Removed = append(res.Removed, ups)
+			res.remove(ups, unmanaged)
 			i++
 		case cmp > 0:
 			// Upstream is ahead: it's missing a label
@@ -51,7 +60,7 @@ func Diff(upstream, local Labels, colorize bool) (LabelsDiff, error) {
 
 	// Consume all upstream that are not present in local
 	for ; i < len(upstream); i++ {
-		res.Removed = append(res.Removed, upstream[i])
+		res.remove(upstream[i], unmanaged)
 	}
 
 	// Consume all local that are not present upstream
@@ -62,28 +71,123 @@ func Diff(upstream, local Labels, colorize bool) (LabelsDiff, error) {
 	return res, nil
 }
 
+// Renames returns the upstream labels renamed in the local config, mapping
+// their old names to the new ones.
+func Renames(upstream, local Labels) map[string]string {
+	_, _, renamed := findRenamed(upstream, local)
+	res := map[string]string{}
+	for _, m := range renamed {
+		res[m.Old.Name] = m.New.Name
+	}
+	return res
+}
+
+// findRenamed returns the renamed labels, together with the upstream and
+// local labels left to compare.
+func findRenamed(upstream, local Labels) (Labels, Labels, []ModifiedLabel) {
+	prev := local.previousNames()
+	if len(prev) == 0 {
+		return upstream, local, nil
+	}
+
+	upsByName := map[string]Label{}
+	for _, l := range upstream {
+		upsByName[l.Name] = l
+	}
+	locNames := stringset{}
+	for _, l := range local {
+		locNames[l.Name] = struct{}{}
+	}
+
+	var renamed []ModifiedLabel
+	renamedUps, renamedLoc := stringset{}, stringset{}
+	for _, l := range local {
+		if _, ok := upsByName[l.Name]; ok {
+			// Already there: nothing to rename.
+			continue
+		}
+		for _, p := range prev[l.Name] {
+			u, ok := upsByName[p]
+			if !ok {
+				continue
+			}
+			if _, ok := renamedUps[p]; ok {
+				continue
+			}
+			if _, ok := locNames[p]; ok {
+				continue
+			}
+			renamed = append(renamed, ModifiedLabel{Old: u, New: l})
+			renamedUps[p] = struct{}{}
+			renamedLoc[l.Name] = struct{}{}
+			break
+		}
+	}
+
+	var ups, loc Labels
+	for _, l := range upstream {
+		if _, ok := renamedUps[l.Name]; !ok {
+			ups = append(ups, l)
+		}
+	}
+	for _, l := range local {
+		if _, ok := renamedLoc[l.Name]; !ok {
+			loc = append(loc, l)
+		}
+	}
+	return ups, loc, renamed
+}
+
 // LabelsDiff contains the diff of two lists of labels.
 type LabelsDiff struct {
 	Modified []ModifiedLabel
 	Added    Labels
 	Removed  Labels
+	// Ignored contains the upstream labels not managed by the local
+	// config, which would otherwise be removed. They are left untouched.
+	Ignored  Labels
 	Colorize bool
 }
 
+func (d *LabelsDiff) remove(l Label, unmanaged func(Label) bool) {
+	if unmanaged != nil && unmanaged(l) {
+		d.Ignored = append(d.Ignored, l)
+		return
+	}
+	d.Removed = append(d.Removed, l)
+}
+
 // Empty returns true if the diff is empty.
 func (d LabelsDiff) Empty() bool {
 	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
 }
 
+// Summary returns the number of changes in the diff, by kind.
+func (d LabelsDiff) Summary() string {
+	res := fmt.Sprintf("%d added, %d modified, %d removed", len(d.Added), len(d.Modified), len(d.Removed))
+	if len(d.Ignored) > 0 {
+		res += fmt.Sprintf(" (%d ignored)", len(d.Ignored))
+	}
+	return res
+}
+
 func (d LabelsDiff) String() string {
 	var old, curr []string
 
 	cleanup := func(l Label) Label {
-		// Get rid of distracting information in the diff.
-		return Label{
+		// Get rid of distracting information in the diff, including the
+		// default visibilities.
+		res := Label{
 			Name:  l.Name,
 			Color: l.Color,
 		}
+		if l.LabelList != Show {
+			res.LabelList = l.LabelList
+		}
+		if l.MessageList != Show {
+			res.MessageList = l.MessageList
+		}
+		return res
 	}
 
 	for _, ml := range d.Modified {
@@ -114,6 +218,15 @@ func (d LabelsDiff) String() string {
 			fmt.Sprint(d.Modified),
 		)
 	}
+	if len(d.Ignored) > 0 {
+		if s == "" {
+			s = "--- Current\n+++ TO BE APPLIED\n"
+		}
+		s += "@@ ignored labels (unmanaged) @@\n"
+		for _, l := range d.Ignored {
+			s += " " + cleanup(l).String() + "\n"
+		}
+	}
 	if d.Colorize {
 		s = reporting.ColorizeDiff(s)
 	}
@@ -127,14 +240,23 @@ type ModifiedLabel struct {
 	New Label
 }
 
+// Renamed returns whether the label changed name.
+func (m ModifiedLabel) Renamed() bool {
+	return m.Old.Name != m.New.Name
+}
+
 // Validate makes sure that a diff is valid and safe to apply.
-func Validate(d LabelsDiff, filters filter.Filters) error {
+//
+// labels and filters are the ones resulting from the diff. Labels can only be
+// removed together with the labels nested into them.
+func Validate(d LabelsDiff, labels Labels, filters filter.Filters) error {
 	for _, l := range d.Removed {
 		if filters.HasLabel(l.Name) {
 			return fmt.Errorf("cannot remove label %q, used in filter", l.Name)
 		}
 	}
-	return nil
+	kept := append(append(Labels{}, labels...), d.Ignored...)
+	return checkRemovedParents(d.Removed, kept)
 }
 
 type byName Labels
@@ -21,6 +21,10 @@ func (ls Labels) String() string {
 
 // Validate checks the given labels for possible issues.
 func (ls Labels) Validate() error {
+	if err := ls.validatePreviousNames(); err != nil {
+		return err
+	}
+
 	lmap := stringset{}
 
 	for _, l := range ls {
@@ -38,11 +42,71 @@ func (ls Labels) Validate() error {
 			return fmt.Errorf("label %q provided multiple times", n)
 		}
 		lmap[n] = struct{}{}
+		if err := l.validateVisibility(); err != nil {
+			return err
+		}
+		if err := l.validateColor(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (ls Labels) validatePreviousNames() error {
+	names := stringset{}
+	for _, l := range ls {
+		names[l.Name] = struct{}{}
+	}
+	prev := map[string]string{}
+	for _, l := range ls {
+		for _, p := range l.PreviousNames {
+			if p == "" || p == l.Name {
+				return fmt.Errorf("invalid previous name %q of label %q", p, l.Name)
+			}
+			if _, ok := names[p]; ok {
+				return fmt.Errorf("label %q is also a previous name of %q", p, l.Name)
+			}
+			if other, ok := prev[p]; ok {
+				return fmt.Errorf("%q is a previous name of both %q and %q", p, other, l.Name)
+			}
+			prev[p] = l.Name
+		}
+	}
+	return nil
+}
+
+// previousNames returns the names each label had before being renamed. The
+// labels nested into a renamed label were renamed as well, so they also get
+// the previous names of their parents.
+func (ls Labels) previousNames() map[string][]string {
+	byName := map[string]Label{}
+	for _, l := range ls {
+		byName[l.Name] = l
+	}
+
+	res := map[string][]string{}
+	for _, l := range ls {
+		names := append([]string(nil), l.PreviousNames...)
+		for i := range l.Name {
+			if l.Name[i] != '/' {
+				continue
+			}
+			parent, ok := byName[l.Name[:i]]
+			if !ok {
+				continue
+			}
+			for _, p := range parent.PreviousNames {
+				names = append(names, p+l.Name[i:])
+			}
+		}
+		if len(names) > 0 {
+			res[l.Name] = names
+		}
+	}
+	return res
+}
+
 type stringset map[string]struct{}
 
 // Label contains information about a Gmail label.
@@ -50,6 +114,43 @@ type Label struct {
 	ID    string
 	Name  string
 	Color *Color
+	// LabelList is the visibility of the label in the label list.
+	LabelList Visibility
+	// MessageList is the visibility of the label in the message list.
+	MessageList Visibility
+	// PreviousNames are the names the label had before being renamed in
+	// the local config. Upstream labels with those names are renamed,
+	// instead of being removed.
+	PreviousNames []string
+}
+
+func (l Label) validateColor() error {
+	if l.Color == nil {
+		return nil
+	}
+	if err := checkColor(l.Color.Background); err != nil {
+		return fmt.Errorf("label %q: background: %w", l.Name, err)
+	}
+	if err := checkColor(l.Color.Text); err != nil {
+		return fmt.Errorf("label %q: text: %w", l.Name, err)
+	}
+	return nil
+}
+
+func (l Label) validateVisibility() error {
+	switch l.LabelList {
+	case "", Show, Hide, ShowIfUnread:
+	default:
+		return fmt.Errorf("label %q: invalid label list visibility %q (allowed: %s, %s, %s)",
+			l.Name, l.LabelList, Show, Hide, ShowIfUnread)
+	}
+	switch l.MessageList {
+	case "", Show, Hide:
+	default:
+		return fmt.Errorf("label %q: invalid message list visibility %q (allowed: %s, %s)",
+			l.Name, l.MessageList, Show, Hide)
+	}
+	return nil
 }
 
 func (l Label) String() string {
@@ -64,14 +165,59 @@ func (l Label) String() string {
 		ss = append(ss, fmt.Sprintf("color: %s, %s",
 			l.Color.Background, l.Color.Text))
 	}
+	if l.LabelList != "" {
+		ss = append(ss, fmt.Sprintf("label list: %s", l.LabelList))
+	}
+	if l.MessageList != "" {
+		ss = append(ss, fmt.Sprintf("message list: %s", l.MessageList))
+	}
 
 	return strings.Join(ss, "; ")
 }
 
+// Usage is the number of messages and threads with a label.
+type Usage struct {
+	Messages int64
+	Threads  int64
+}
+
+// Empty returns whether no messages have the label.
+func (u Usage) Empty() bool {
+	return u.Messages == 0 && u.Threads == 0
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("%d messages in %d threads", u.Messages, u.Threads)
+}
+
+// Visibility is the visibility of a label in one of the Gmail lists. Empty
+// means unspecified.
+type Visibility string
+
+// Allowed visibilities. ShowIfUnread is only allowed in the label list.
+const (
+	Show         Visibility = "show"
+	Hide         Visibility = "hide"
+	ShowIfUnread Visibility = "showIfUnread"
+)
+
+// sameVisibility returns whether the upstream visibility satisfies the local
+// one. Unspecified visibilities are shown by Gmail.
+func sameVisibility(upstream, local Visibility) bool {
+	if local == "" {
+		return true
+	}
+	if upstream == "" {
+		upstream = Show
+	}
+	return upstream == local
+}
+
 // Color is the color of a label.
 //
 // See https://developers.google.com/gmail/api/v1/reference/users/labels
-// for the list of possible colors.
+// for the list of possible colors. In the config, colors can also be given
+// by name (e.g. 'red' or 'blue-light').
 type Color struct {
 	Background string
 	Text       string
@@ -80,12 +226,16 @@ type Color struct {
 // Equivalent returns true if two labels can be considered equal, despite a
 // different ID.
 //
-// Unspecified color is also ignored.
+// Unspecified color and visibilities are also ignored.
 func Equivalent(upstream, local Label) bool {
 	// Ignore ID
 	if upstream.Name != local.Name {
 		return false
 	}
+	if !sameVisibility(upstream.LabelList, local.LabelList) ||
+		!sameVisibility(upstream.MessageList, local.MessageList) {
+		return false
+	}
 
 	upsHasColor := upstream.Color != nil
 	locHasColor := local.Color != nil
@@ -102,22 +252,28 @@ func Equivalent(upstream, local Label) bool {
 }
 
 // FromConfig creates labels from the config format.
-func FromConfig(ls []v1alpha3.Label) Labels {
+//
+// The parents of nested labels are needed in Gmail, so the missing ones are
+// added. The labels declared without their parent are returned as orphans.
+func FromConfig(ls []v1alpha3.Label) (Labels, []string) {
 	var res Labels
 
 	for _, l := range ls {
 		var color *Color
 		if l.Color != nil {
 			color = &Color{
-				Background: l.Color.Background,
-				Text:       l.Color.Text,
+				Background: resolveColor(l.Color.Background),
+				Text:       resolveColor(l.Color.Text),
 			}
 		}
 		res = append(res, Label{
-			Name:  l.Name,
-			Color: color,
+			Name:          l.Name,
+			Color:         color,
+			LabelList:     Visibility(l.LabelList),
+			MessageList:   Visibility(l.MessageList),
+			PreviousNames: l.PreviousNames,
 		})
 	}
 
-	return res
+	return withParents(res)
 }
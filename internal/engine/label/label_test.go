@@ -32,6 +32,28 @@ func TestInvalid(t *testing.T) {
 				{Name: "abc"},
 			},
 		},
+		{
+			"invalid label list visibility",
+			Labels{{Name: "abc", LabelList: "hidden"}},
+		},
+		{
+			"show if unread in message list",
+			Labels{{Name: "abc", MessageList: ShowIfUnread}},
+		},
+		{
+			"previous name declared",
+			Labels{
+				{Name: "abc", PreviousNames: []string{"def"}},
+				{Name: "def"},
+			},
+		},
+		{
+			"previous name repeated",
+			Labels{
+				{Name: "abc", PreviousNames: []string{"old"}},
+				{Name: "def", PreviousNames: []string{"old"}},
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -95,6 +117,116 @@ func TestValidateUsed(t *testing.T) {
 			Action:   filter.Actions{AddLabel: "foo"},
 		},
 	}
-	err := Validate(d, fs)
+	err := Validate(d, nil, fs)
 	assert.NotNil(t, err)
 }
+
+func TestDiffUnmanaged(t *testing.T) {
+	upstream := Labels{{ID: "1", Name: "shared/a"}, {ID: "2", Name: "old"}, {ID: "3", Name: "work"}}
+	local := Labels{{Name: "work"}}
+	unmanaged := func(l Label) bool { return MatchPattern("shared/**", l.Name) }
+
+	d, err := Diff(upstream, local, unmanaged, false)
+	assert.Nil(t, err)
+	assert.Equal(t, Labels{{ID: "2", Name: "old"}}, d.Removed)
+	assert.Equal(t, Labels{{ID: "1", Name: "shared/a"}}, d.Ignored)
+	assert.Contains(t, d.String(), "@@ ignored labels (unmanaged) @@\n shared/a\n")
+}
+
+func TestEquivalentVisibility(t *testing.T) {
+	cases := []struct {
+		name     string
+		upstream Label
+		local    Label
+		want     bool
+	}{
+		{
+			"unspecified",
+			Label{Name: "a", LabelList: Hide, MessageList: Hide},
+			Label{Name: "a"},
+			true,
+		},
+		{
+			"same",
+			Label{Name: "a", LabelList: ShowIfUnread},
+			Label{Name: "a", LabelList: ShowIfUnread},
+			true,
+		},
+		{
+			"default upstream",
+			Label{Name: "a"},
+			Label{Name: "a", LabelList: Show, MessageList: Show},
+			true,
+		},
+		{
+			"label list",
+			Label{Name: "a", LabelList: Show},
+			Label{Name: "a", LabelList: Hide},
+			false,
+		},
+		{
+			"message list",
+			Label{Name: "a", MessageList: Hide},
+			Label{Name: "a", MessageList: Show},
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Equivalent(tc.upstream, tc.local))
+		})
+	}
+}
+
+func TestDiffVisibility(t *testing.T) {
+	upstream := Labels{{ID: "1", Name: "archive", LabelList: Show, MessageList: Show}}
+	local := Labels{{Name: "archive", LabelList: Hide}}
+
+	d, err := Diff(upstream, local, nil, false)
+	assert.Nil(t, err)
+	assert.Len(t, d.Modified, 1)
+	// Default visibilities are not shown.
+	assert.Contains(t, d.String(), "-archive\n+archive; label list: hide\n")
+}
+
+func TestDiffRenamed(t *testing.T) {
+	upstream := Labels{
+		{ID: "1", Name: "work"},
+		{ID: "2", Name: "work/reports"},
+		{ID: "3", Name: "news"},
+		{ID: "4", Name: "kept"},
+	}
+	local := Labels{
+		{Name: "job", PreviousNames: []string{"work"}},
+		{Name: "job/reports"},
+		{Name: "newsletters", PreviousNames: []string{"news"}},
+		// Not renamed: the previous name is not upstream.
+		{Name: "other", PreviousNames: []string{"missing"}},
+		{Name: "kept"},
+	}
+
+	d, err := Diff(upstream, local, nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []ModifiedLabel{
+		{Old: upstream[0], New: local[0]},
+		{Old: upstream[1], New: local[1]},
+		{Old: upstream[2], New: local[2]},
+	}, d.Modified)
+	assert.Equal(t, Labels{local[3]}, d.Added)
+	assert.Empty(t, d.Removed)
+	assert.Equal(t, map[string]string{
+		"work":         "job",
+		"work/reports": "job/reports",
+		"news":         "newsletters",
+	}, Renames(upstream, local))
+}
+
+func TestDiffRenamedAlreadyApplied(t *testing.T) {
+	upstream := Labels{{ID: "1", Name: "job"}}
+	local := Labels{{Name: "job", PreviousNames: []string{"work"}}}
+
+	d, err := Diff(upstream, local, nil, false)
+	assert.Nil(t, err)
+	assert.True(t, d.Empty())
+}
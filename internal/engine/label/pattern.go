@@ -0,0 +1,46 @@
+package label
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ValidatePattern returns an error if the label pattern is malformed.
+func ValidatePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid label pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// MatchPattern returns whether the label name matches the pattern.
+//
+// In patterns, '*' matches any part of a label name between slashes and '**'
+// any number of nested labels, including none (e.g. 'Work/**' matches 'Work'
+// and all the labels nested into it).
+func MatchPattern(pattern, name string) bool {
+	return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		// Try to match any number of parts, including none.
+		for i := 0; i <= len(name); i++ {
+			if matchParts(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchParts(pattern[1:], name[1:])
+}
@@ -0,0 +1,46 @@
+package label
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"Work", "Work", true},
+		{"Work", "Work/a", false},
+		{"Work/*", "Work/a", true},
+		{"Work/*", "Work", false},
+		{"Work/*", "Work/a/b", false},
+		{"Work/**", "Work", true},
+		{"Work/**", "Work/a/b", true},
+		{"Work/**", "Workshop", false},
+		{"**/News", "Work/News", true},
+		{"**/News", "News", true},
+		{"**/News", "Work/Newsletters", false},
+		{"Work/**/News", "Work/News", true},
+		{"Work/**/News", "Work/a/b/News", true},
+		{"Work/**/News", "Home/News", false},
+		{"**", "Work/a", true},
+		{"W*k", "Work", true},
+		{"W*k", "Work/a", false},
+		{"W?rk", "Work", true},
+		{"[HW]ome", "Home", true},
+		{"[HW]ome", "Some", false},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, MatchPattern(tc.pattern, tc.name), "%s ~ %s", tc.pattern, tc.name)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.Nil(t, ValidatePattern("Work/**"))
+	assert.NotNil(t, ValidatePattern("[a"))
+}
@@ -0,0 +1,98 @@
+package label
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Parent returns the name of the parent of the label with the given name, or
+// an empty string for top level labels.
+func Parent(name string) string {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// isNested returns whether the label is nested into the given one, at any
+// level.
+func isNested(name, ancestor string) bool {
+	return strings.HasPrefix(name, ancestor+"/")
+}
+
+// withParents returns the labels, followed by their missing parents at any
+// level. The orphans are the labels whose parent was missing.
+func withParents(ls Labels) (res Labels, orphans []string) {
+	names := stringset{}
+	for _, l := range ls {
+		names[l.Name] = struct{}{}
+	}
+	res = append(res, ls...)
+
+	for _, l := range ls {
+		if p := Parent(l.Name); p != "" {
+			if _, ok := names[p]; !ok {
+				orphans = append(orphans, l.Name)
+			}
+		}
+		for p := Parent(l.Name); p != ""; p = Parent(p) {
+			if _, ok := names[p]; ok {
+				continue
+			}
+			names[p] = struct{}{}
+			res = append(res, Label{Name: p})
+		}
+	}
+	return res, orphans
+}
+
+// ParentsFirst returns the labels in a topological order of the label tree:
+// every label comes after its parents, and siblings are sorted by name.
+// Labels can be created in this order and removed in the reverse one.
+func ParentsFirst(ls Labels) Labels {
+	byName := map[string]Label{}
+	var names []string
+	for _, l := range ls {
+		byName[l.Name] = l
+		names = append(names, l.Name)
+	}
+	sort.Strings(names)
+
+	res := make(Labels, 0, len(ls))
+	visited := stringset{}
+	var visit func(name string)
+	visit = func(name string) {
+		if _, ok := visited[name]; ok {
+			return
+		}
+		visited[name] = struct{}{}
+		// Intermediate labels may be missing: the nearest ancestor in the
+		// list has to come first.
+		for p := Parent(name); p != ""; p = Parent(p) {
+			if _, ok := byName[p]; ok {
+				visit(p)
+				break
+			}
+		}
+		res = append(res, byName[name])
+	}
+	for _, n := range names {
+		visit(n)
+	}
+	return res
+}
+
+// checkRemovedParents makes sure that no label is removed while one of the
+// labels nested into it is kept: removing the children as well is fine.
+func checkRemovedParents(removed, kept Labels) error {
+	for _, r := range removed {
+		for _, k := range kept {
+			if isNested(k.Name, r.Name) {
+				return fmt.Errorf("cannot remove label %q, because its nested label %q is kept", r.Name, k.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package label
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+)
+
+func TestFromConfigParents(t *testing.T) {
+	ls, orphans := FromConfig([]v1alpha3.Label{
+		{Name: "work/projects/gmailctl"},
+		{Name: "work"},
+		{Name: "family/kids"},
+	})
+	assert.Equal(t, []string{"work/projects/gmailctl", "family/kids"}, orphans)
+	assert.Equal(t, []string{
+		"work/projects/gmailctl", "work", "family/kids",
+		"work/projects", "family",
+	}, names(ls))
+	assert.Nil(t, ls.Validate())
+}
+
+func TestParentsFirst(t *testing.T) {
+	ls := Labels{
+		{Name: "b/c/d"},
+		{Name: "a-long-name"},
+		{Name: "b"},
+		{Name: "a/x"},
+		{Name: "b/c"},
+		{Name: "a"},
+	}
+	assert.Equal(t, []string{"a", "a-long-name", "a/x", "b", "b/c", "b/c/d"}, names(ParentsFirst(ls)))
+
+	// Missing intermediate labels.
+	ls = Labels{{Name: "z/y/x"}, {Name: "z"}}
+	assert.Equal(t, []string{"z", "z/y/x"}, names(ParentsFirst(ls)))
+}
+
+func TestValidateRemovedParent(t *testing.T) {
+	cases := []struct {
+		name  string
+		diff  LabelsDiff
+		kept  Labels
+		valid bool
+	}{
+		{
+			name:  "cascade",
+			diff:  LabelsDiff{Removed: Labels{{Name: "a/b"}, {Name: "a"}}},
+			valid: true,
+		},
+		{
+			name: "nested kept",
+			diff: LabelsDiff{Removed: Labels{{Name: "a"}}},
+			kept: Labels{{Name: "a/b"}},
+		},
+		{
+			name: "nested ignored",
+			diff: LabelsDiff{Removed: Labels{{Name: "a"}}, Ignored: Labels{{Name: "a/b/c"}}},
+		},
+		{
+			name:  "prefix only",
+			diff:  LabelsDiff{Removed: Labels{{Name: "a"}}},
+			kept:  Labels{{Name: "ab"}},
+			valid: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.diff, tc.kept, nil)
+			if tc.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func names(ls Labels) []string {
+	var res []string
+	for _, l := range ls {
+		res = append(res, l.Name)
+	}
+	return res
+}
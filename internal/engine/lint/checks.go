@@ -0,0 +1,238 @@
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/cfgtest"
+	"github.com/mbrt/gmailctl/internal/engine/filter"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+func checkDuplicates(c *context) {
+	for j := range c.rules {
+		for i := 0; i < j; i++ {
+			if isDuplicate(c.rules[i], c.rules[j]) {
+				c.report("%s is a duplicate of %s", c.ruleName(j), c.ruleName(i))
+				break
+			}
+		}
+	}
+}
+
+func checkCovered(c *context) {
+	for j, rj := range c.rules {
+		for i := 0; i < j; i++ {
+			ri := c.rules[i]
+			if isDuplicate(ri, rj) || !isCovered(ri, rj) {
+				continue
+			}
+			c.report("%s is covered by %s, which matches all its messages and already applies all its actions",
+				c.ruleName(j), c.ruleName(i))
+			break
+		}
+	}
+}
+
+func checkShadowed(c *context) {
+	for j, rj := range c.rules {
+		for i := 0; i < j; i++ {
+			ri := c.rules[i]
+			if !ri.Actions.Delete || isCovered(ri, rj) || !cfgtest.Implies(rj.Criteria, ri.Criteria) {
+				continue
+			}
+			c.report("%s is shadowed by %s, which deletes all the messages it matches",
+				c.ruleName(j), c.ruleName(i))
+			break
+		}
+	}
+}
+
+// equivalent returns true if a and b match the same messages.
+func equivalent(a, b parser.CriteriaAST) bool {
+	return cfgtest.Implies(a, b) && cfgtest.Implies(b, a)
+}
+
+func isDuplicate(earlier, later parser.Rule) bool {
+	return equivalent(earlier.Criteria, later.Criteria) &&
+		includesActions(earlier.Actions, later.Actions) &&
+		includesActions(later.Actions, earlier.Actions)
+}
+
+// isCovered returns true if the later rule doesn't add anything to the
+// earlier one.
+func isCovered(earlier, later parser.Rule) bool {
+	return cfgtest.Implies(later.Criteria, earlier.Criteria) &&
+		includesActions(earlier.Actions, later.Actions)
+}
+
+// includesActions returns true if all the actions in b are also applied by a.
+func includesActions(a, b parser.Actions) bool {
+	if (b.Archive && !a.Archive) ||
+		(b.Delete && !a.Delete) ||
+		(b.MarkRead && !a.MarkRead) ||
+		(b.Star && !a.Star) {
+		return false
+	}
+	if b.MarkSpam != nil && (a.MarkSpam == nil || *a.MarkSpam != *b.MarkSpam) {
+		return false
+	}
+	if b.MarkImportant != nil && (a.MarkImportant == nil || *a.MarkImportant != *b.MarkImportant) {
+		return false
+	}
+	if b.Category != "" && b.Category != a.Category {
+		return false
+	}
+	if b.Forward != "" && b.Forward != a.Forward {
+		return false
+	}
+	for _, l := range b.Labels {
+		if !slices.Contains(a.Labels, l) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkUndeclaredLabels(c *context) {
+	if len(c.labels) == 0 {
+		return
+	}
+	declared := map[string]bool{}
+	for _, l := range c.labels {
+		declared[l.Name] = true
+	}
+	for i, r := range c.rules {
+		for _, l := range r.Actions.Labels {
+			if !declared[l] {
+				c.report("%s uses label %q, which is not declared in 'labels'", c.ruleName(i), l)
+			}
+		}
+	}
+}
+
+func checkUnusedLabels(c *context) {
+	used := map[string]bool{}
+	for _, r := range c.rules {
+		for _, l := range r.Actions.Labels {
+			// Parents are implicitly used by their children.
+			comps := strings.Split(l, "/")
+			for i := range comps {
+				used[strings.Join(comps[:i+1], "/")] = true
+			}
+		}
+	}
+	for _, l := range c.labels {
+		if !used[l.Name] {
+			c.report("label %q is not used by any rule", l.Name)
+		}
+	}
+}
+
+func checkContradictoryActions(c *context) {
+	for i, r := range c.rules {
+		a := r.Actions
+		if a.Delete {
+			var also []string
+			if a.Archive {
+				also = append(also, "archives")
+			}
+			if a.Star {
+				also = append(also, "stars")
+			}
+			if a.MarkImportant != nil && *a.MarkImportant {
+				also = append(also, "marks as important")
+			}
+			if a.Category != "" {
+				also = append(also, "categorizes")
+			}
+			if len(a.Labels) > 0 {
+				also = append(also, "labels")
+			}
+			if len(also) > 0 {
+				c.report("%s deletes messages, but also %s them", c.ruleName(i), joinWords(also))
+			}
+		}
+		if a.Archive && a.Star {
+			c.report("%s both archives and stars messages", c.ruleName(i))
+		}
+	}
+}
+
+func checkEscapedValues(c *context) {
+	for i, r := range c.rules {
+		for _, leaf := range collectLeaves(r.Criteria) {
+			if !leaf.IsRaw {
+				continue
+			}
+			for _, arg := range leaf.Args {
+				if msg := escapedValueProblem(leaf.Function, arg); msg != "" {
+					c.report("%s: escaped value %q %s", c.ruleName(i), arg, msg)
+				}
+			}
+		}
+	}
+}
+
+// escapedValueProblem returns what is wrong with an escaped value, if
+// anything.
+func escapedValueProblem(fn parser.FunctionType, arg string) string {
+	tree, err := parser.ParseQuery(fmt.Sprintf("%s:%s", fn, arg))
+	if err != nil {
+		return fmt.Sprintf("is not a valid search query: %v", err)
+	}
+	for _, leaf := range collectLeaves(tree) {
+		if leaf.Function != fn {
+			return fmt.Sprintf("is not entirely restricted to '%s:'; quote it, or group it with parentheses", fn)
+		}
+	}
+	return ""
+}
+
+func checkSplitRules(c *context) {
+	for i, r := range c.rules {
+		fs, err := filter.FromRule(r, filter.DefaultSizeLimit)
+		if err != nil {
+			// Reported when exporting the filters.
+			continue
+		}
+		// Multiple labels produce multiple filters with the same criteria,
+		// but they are not caused by the criteria size.
+		criteria := map[filter.Criteria]bool{}
+		for _, f := range fs {
+			criteria[f.Criteria] = true
+		}
+		if n := len(criteria); n > c.maxFilters {
+			c.report("%s is split into %d Gmail filters; consider simplifying its criteria",
+				c.ruleName(i), n)
+		}
+	}
+}
+
+type leafCollector struct {
+	leaves []*parser.Leaf
+}
+
+func (v *leafCollector) VisitNode(n *parser.Node) {
+	for _, c := range n.Children {
+		c.AcceptVisitor(v)
+	}
+}
+
+func (v *leafCollector) VisitLeaf(n *parser.Leaf) {
+	v.leaves = append(v.leaves, n)
+}
+
+func collectLeaves(tree parser.CriteriaAST) []*parser.Leaf {
+	v := &leafCollector{}
+	tree.AcceptVisitor(v)
+	return v.leaves
+}
+
+func joinWords(ws []string) string {
+	if len(ws) == 1 {
+		return ws[0]
+	}
+	return fmt.Sprintf("%s and %s", strings.Join(ws[:len(ws)-1], ", "), ws[len(ws)-1])
+}
@@ -0,0 +1,203 @@
+// Package lint statically analyses a parsed config, looking for likely
+// mistakes that are not errors per se.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mbrt/gmailctl/internal/engine/label"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+// Severities of the findings.
+const (
+	SeverityOff Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+// Severity is how important a finding is.
+type Severity int
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityOff:
+		return "off"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "<unknown>"
+	}
+}
+
+// ParseSeverity parses the name of a severity (e.g. 'warning').
+func ParseSeverity(s string) (Severity, error) {
+	for _, sev := range []Severity{SeverityOff, SeverityInfo, SeverityWarning, SeverityError} {
+		if strings.EqualFold(s, sev.String()) {
+			return sev, nil
+		}
+	}
+	return SeverityOff, fmt.Errorf("unknown severity %q", s)
+}
+
+// DefaultMaxFilters is the default number of Gmail filters a rule can be
+// split into, before being reported.
+const DefaultMaxFilters = 3
+
+// Options controls the checks performed by the linter.
+type Options struct {
+	// Severities overrides the default severity of the checks, by name.
+	// A check can be disabled by setting its severity to 'off'.
+	Severities map[string]Severity
+	// MaxFilters is the number of Gmail filters a rule can be split into,
+	// before being reported. If zero, DefaultMaxFilters is used.
+	MaxFilters int
+}
+
+// Finding is a problem found by a check.
+type Finding struct {
+	Check    string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s]", f.Severity, f.Message, f.Check)
+}
+
+// Check describes a check performed by the linter.
+type Check struct {
+	Name        string
+	Severity    Severity
+	Description string
+
+	run func(c *context)
+}
+
+// Checks returns all the available checks, with their default severity.
+func Checks() []Check {
+	return []Check{
+		{
+			Name:        "duplicate-rule",
+			Severity:    SeverityError,
+			Description: "the rule is equivalent to an earlier one",
+			run:         checkDuplicates,
+		},
+		{
+			Name:        "covered-rule",
+			Severity:    SeverityWarning,
+			Description: "an earlier rule matches the same messages and applies the same actions",
+			run:         checkCovered,
+		},
+		{
+			Name:        "shadowed-rule",
+			Severity:    SeverityWarning,
+			Description: "an earlier rule deletes all the messages matched by the rule",
+			run:         checkShadowed,
+		},
+		{
+			Name:        "undeclared-label",
+			Severity:    SeverityError,
+			Description: "a label is used by a rule, but not declared in 'labels'",
+			run:         checkUndeclaredLabels,
+		},
+		{
+			Name:        "unused-label",
+			Severity:    SeverityInfo,
+			Description: "a label is declared in 'labels', but no rule uses it",
+			run:         checkUnusedLabels,
+		},
+		{
+			Name:        "contradictory-actions",
+			Severity:    SeverityWarning,
+			Description: "the actions of the rule contradict each other",
+			run:         checkContradictoryActions,
+		},
+		{
+			Name:        "escaped-value",
+			Severity:    SeverityWarning,
+			Description: "an 'isEscaped' value is not going to be interpreted as a single operator",
+			run:         checkEscapedValues,
+		},
+		{
+			Name:        "split-rule",
+			Severity:    SeverityWarning,
+			Description: "the rule is split into many Gmail filters",
+			run:         checkSplitRules,
+		},
+	}
+}
+
+// Lint runs all the enabled checks on the given rules and labels.
+//
+// Label checks are skipped when no labels are declared, since labels
+// management is optional.
+func Lint(rules []parser.Rule, labels label.Labels, opts Options) ([]Finding, error) {
+	checks := Checks()
+	known := map[string]bool{}
+	for _, c := range checks {
+		known[c.Name] = true
+	}
+	var unknown []string
+	for name := range opts.Severities {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown checks: %s", strings.Join(unknown, ", "))
+	}
+	if opts.MaxFilters <= 0 {
+		opts.MaxFilters = DefaultMaxFilters
+	}
+
+	var res []Finding
+	for _, c := range checks {
+		sev := c.Severity
+		if s, ok := opts.Severities[c.Name]; ok {
+			sev = s
+		}
+		if sev == SeverityOff {
+			continue
+		}
+		ctx := &context{
+			rules:      rules,
+			labels:     labels,
+			maxFilters: opts.MaxFilters,
+		}
+		c.run(ctx)
+		for _, msg := range ctx.messages {
+			res = append(res, Finding{
+				Check:    c.Name,
+				Severity: sev,
+				Message:  msg,
+			})
+		}
+	}
+
+	return res, nil
+}
+
+// context is the input of a check, and collects its findings.
+type context struct {
+	rules      []parser.Rule
+	labels     label.Labels
+	maxFilters int
+	messages   []string
+}
+
+func (c *context) report(format string, args ...interface{}) {
+	c.messages = append(c.messages, fmt.Sprintf(format, args...))
+}
+
+func (c *context) ruleName(i int) string {
+	return parser.RuleName(i, c.rules[i].Source)
+}
@@ -0,0 +1,164 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/mbrt/gmailctl/internal/engine/config/v1alpha3"
+	"github.com/mbrt/gmailctl/internal/engine/label"
+	"github.com/mbrt/gmailctl/internal/engine/parser"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func lintRules(t *testing.T, rules []cfg.Rule, labels []cfg.Label, opts Options) []string {
+	t.Helper()
+	prs, err := parser.Parse(cfg.Config{Rules: rules})
+	require.Nil(t, err)
+	ls, _ := label.FromConfig(labels)
+	fs, err := Lint(prs, ls, opts)
+	require.Nil(t, err)
+	var res []string
+	for _, f := range fs {
+		res = append(res, f.String())
+	}
+	return res
+}
+
+func TestLintRules(t *testing.T) {
+	rules := []cfg.Rule{
+		{
+			Filter:  cfg.FilterNode{Or: []cfg.FilterNode{{From: "a"}, {From: "b"}}},
+			Actions: cfg.Actions{Archive: true, Labels: []string{"work"}},
+			Source:  "config.jsonnet:3",
+		},
+		{
+			// Duplicate.
+			Filter:  cfg.FilterNode{Or: []cfg.FilterNode{{From: "b"}, {From: "a"}}},
+			Actions: cfg.Actions{Labels: []string{"work"}, Archive: true},
+		},
+		{
+			// Covered by rule #0.
+			Filter: cfg.FilterNode{And: []cfg.FilterNode{
+				{From: "a"},
+				{Subject: "hello"},
+			}},
+			Actions: cfg.Actions{Archive: true},
+		},
+		{
+			// Not covered: it applies more actions.
+			Filter:  cfg.FilterNode{From: "a"},
+			Actions: cfg.Actions{Archive: true, Star: true},
+		},
+		{
+			Filter:  cfg.FilterNode{List: "spam"},
+			Actions: cfg.Actions{Delete: true, Labels: []string{"spam"}, MarkImportant: boolPtr(true)},
+		},
+		{
+			// Shadowed.
+			Filter:  cfg.FilterNode{And: []cfg.FilterNode{{List: "spam"}, {To: "me"}}},
+			Actions: cfg.Actions{MarkRead: true},
+		},
+	}
+	labels := []cfg.Label{{Name: "work"}, {Name: "unused"}}
+
+	got := lintRules(t, rules, labels, Options{})
+	assert.Equal(t, []string{
+		`error: rule #1 is a duplicate of rule #0 (config.jsonnet:3) [duplicate-rule]`,
+		`warning: rule #2 is covered by rule #0 (config.jsonnet:3), which matches all its messages and already applies all its actions [covered-rule]`,
+		`warning: rule #5 is shadowed by rule #4, which deletes all the messages it matches [shadowed-rule]`,
+		`error: rule #4 uses label "spam", which is not declared in 'labels' [undeclared-label]`,
+		`info: label "unused" is not used by any rule [unused-label]`,
+		`warning: rule #3 both archives and stars messages [contradictory-actions]`,
+		`warning: rule #4 deletes messages, but also marks as important and labels them [contradictory-actions]`,
+	}, got)
+
+	// Change the severities.
+	got = lintRules(t, rules, labels, Options{
+		Severities: map[string]Severity{
+			"duplicate-rule":        SeverityOff,
+			"covered-rule":          SeverityOff,
+			"shadowed-rule":         SeverityOff,
+			"undeclared-label":      SeverityInfo,
+			"unused-label":          SeverityOff,
+			"contradictory-actions": SeverityError,
+		},
+	})
+	assert.Equal(t, []string{
+		`info: rule #4 uses label "spam", which is not declared in 'labels' [undeclared-label]`,
+		`error: rule #3 both archives and stars messages [contradictory-actions]`,
+		`error: rule #4 deletes messages, but also marks as important and labels them [contradictory-actions]`,
+	}, got)
+}
+
+func TestLintLabelsNotManaged(t *testing.T) {
+	rules := []cfg.Rule{
+		{
+			Filter:  cfg.FilterNode{From: "a"},
+			Actions: cfg.Actions{Labels: []string{"foo"}},
+		},
+	}
+	assert.Empty(t, lintRules(t, rules, nil, Options{}))
+}
+
+func TestLintParentLabels(t *testing.T) {
+	rules := []cfg.Rule{
+		{
+			Filter:  cfg.FilterNode{From: "a"},
+			Actions: cfg.Actions{Labels: []string{"work/team"}},
+		},
+	}
+	labels := []cfg.Label{{Name: "work"}, {Name: "work/team"}}
+	assert.Empty(t, lintRules(t, rules, labels, Options{}))
+}
+
+func TestLintEscapedValues(t *testing.T) {
+	rules := []cfg.Rule{
+		{
+			Filter:  cfg.FilterNode{Subject: `"foo bar"`, IsEscaped: true},
+			Actions: cfg.Actions{Archive: true},
+		},
+		{
+			Filter:  cfg.FilterNode{Subject: `foo bar`, IsEscaped: true},
+			Actions: cfg.Actions{Archive: true},
+		},
+		{
+			Filter:  cfg.FilterNode{From: `(a`, IsEscaped: true},
+			Actions: cfg.Actions{Archive: true},
+		},
+	}
+	got := lintRules(t, rules, nil, Options{})
+	assert.Equal(t, []string{
+		`warning: rule #1: escaped value "foo bar" is not entirely restricted to 'subject:'; quote it, or group it with parentheses [escaped-value]`,
+		`warning: rule #2: escaped value "(a" is not a valid search query: parsing query "from:(a": missing closing ')' [escaped-value]`,
+	}, got)
+}
+
+func TestLintSplitRules(t *testing.T) {
+	var senders []cfg.FilterNode
+	for i := 0; i < 100; i++ {
+		senders = append(senders, cfg.FilterNode{From: strings.Repeat("a", i+1)})
+	}
+	rules := []cfg.Rule{
+		{
+			Filter:  cfg.FilterNode{Or: senders},
+			Actions: cfg.Actions{Archive: true},
+		},
+	}
+	assert.Equal(t, []string{
+		`warning: rule #0 is split into 5 Gmail filters; consider simplifying its criteria [split-rule]`,
+	}, lintRules(t, rules, nil, Options{}))
+	assert.Empty(t, lintRules(t, rules, nil, Options{MaxFilters: 5}))
+}
+
+func TestLintUnknownCheck(t *testing.T) {
+	_, err := Lint(nil, nil, Options{
+		Severities: map[string]Severity{"foo": SeverityError},
+	})
+	assert.NotNil(t, err)
+}
@@ -13,6 +13,20 @@ import (
 type Rule struct {
 	Criteria CriteriaAST
 	Actions  Actions
+	// Tags are the tags of the rule in the config.
+	Tags []string `yaml:"tags,omitempty"`
+	// Source is the position of the rule in the config files, when known.
+	Source string `yaml:"-"`
+}
+
+// RuleName returns a human readable reference to the i-th rule, which
+// includes its position in the config files when known (e.g.
+// 'rule #3 (config.jsonnet:12)').
+func RuleName(i int, source string) string {
+	if source == "" {
+		return fmt.Sprintf("rule #%d", i)
+	}
+	return fmt.Sprintf("rule #%d (%s)", i, source)
 }
 
 // Actions contains the actions to be applied to a set of emails.
@@ -28,7 +42,7 @@ func Parse(config cfg.Config) ([]Rule, error) {
 		r, err := parseRule(rule)
 		if err != nil {
 			return nil, errors.WithDetails(
-				fmt.Errorf("rule #%d: %w", i, err),
+				fmt.Errorf("%s: %w", RuleName(i, rule.Source), err),
 				fmt.Sprintf("Rule: %s", reporting.Prettify(rule, false)),
 			)
 		}
@@ -55,6 +69,8 @@ func parseRule(rule cfg.Rule) (Rule, error) {
 	return Rule{
 		Criteria: scrit,
 		Actions:  Actions(rule.Actions),
+		Tags:     rule.Tags,
+		Source:   rule.Source,
 	}, nil
 }
 
@@ -0,0 +1,323 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseQuery parses a query in Gmail search syntax into a criteria tree.
+//
+// Operators that map directly into a function (e.g. 'from:' or 'list:') are
+// translated into the corresponding leaves, free words become 'has' leaves,
+// while all the other operators (e.g. 'filename:pdf') are kept verbatim as
+// 'query' leaves, one argument per operator.
+//
+// Only a subset of the syntax is supported. Advanced operators like AROUND
+// cause an error.
+func ParseQuery(query string) (CriteriaAST, error) {
+	p := queryParser{tokens: tokenizeQuery(query)}
+	children, err := p.parseSequence(queryContext{fn: FunctionHas}, tokenEOF)
+	if err != nil {
+		return nil, fmt.Errorf("parsing query %q: %w", query, err)
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("parsing query %q: empty query", query)
+	}
+	return SimplifyCriteria(groupChildren(OperationAnd, children))
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenWord
+	tokenQuoted
+	tokenNot
+	tokenOpenAnd
+	tokenCloseAnd
+	tokenOpenOr
+	tokenCloseOr
+)
+
+type queryToken struct {
+	typ  tokenType
+	text string
+	// glued is true when the token immediately follows the previous one,
+	// without separating spaces.
+	glued bool
+}
+
+func tokenizeQuery(query string) []queryToken {
+	var res []queryToken
+	rs := []rune(query)
+	glued := false
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			glued = false
+			i++
+			continue
+		case r == '(':
+			res = append(res, queryToken{tokenOpenAnd, "(", glued})
+			i++
+		case r == ')':
+			res = append(res, queryToken{tokenCloseAnd, ")", glued})
+			i++
+		case r == '{':
+			res = append(res, queryToken{tokenOpenOr, "{", glued})
+			i++
+		case r == '}':
+			res = append(res, queryToken{tokenCloseOr, "}", glued})
+			i++
+		case r == '-' && (!glued || opensGroup(res[len(res)-1])):
+			// A dash negates the next term at the start of a term, also
+			// right after an opening bracket (e.g. '(-from:a)').
+			res = append(res, queryToken{tokenNot, "-", glued})
+			i++
+		case r == '"':
+			// Look for the closing quote. An unterminated quote extends
+			// to the end of the query.
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			res = append(res, queryToken{tokenQuoted, string(rs[i+1 : min(j, len(rs))]), glued})
+			i = j + 1
+		default:
+			j := i
+			for j < len(rs) && !unicode.IsSpace(rs[j]) && !strings.ContainsRune(`(){}"`, rs[j]) {
+				j++
+			}
+			res = append(res, queryToken{tokenWord, string(rs[i:j]), glued})
+			i = j
+		}
+		glued = true
+	}
+
+	return res
+}
+
+func opensGroup(t queryToken) bool {
+	return t.typ == tokenOpenAnd || t.typ == tokenOpenOr
+}
+
+// queryContext is the function applied to the words found while parsing.
+//
+// For example in 'from:(a b)' both 'a' and 'b' are parsed in the context of
+// the 'from' function.
+type queryContext struct {
+	fn FunctionType
+	// operator is set for functions that have no corresponding type and
+	// need to be kept verbatim (e.g. 'filename').
+	operator string
+}
+
+func (c queryContext) leaf(arg string, quoted bool) *Leaf {
+	if c.operator != "" {
+		if quoted {
+			arg = fmt.Sprintf(`"%s"`, arg)
+		}
+		arg = fmt.Sprintf("%s:%s", c.operator, arg)
+	}
+	return &Leaf{
+		Function: c.fn,
+		Grouping: OperationNone,
+		Args:     []string{arg},
+	}
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.pos >= len(p.tokens) {
+		return queryToken{typ: tokenEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.peek()
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// parseSequence parses a list of terms until the closing token is found.
+//
+// Terms joined by 'OR' are grouped together, as in Gmail the 'OR' operator
+// takes precedence over the implicit 'AND'.
+func (p *queryParser) parseSequence(ctx queryContext, closing tokenType) ([]CriteriaAST, error) {
+	var (
+		res     []CriteriaAST
+		pendOr  bool
+		orChain []CriteriaAST
+	)
+
+	flushOr := func() {
+		if len(orChain) > 0 {
+			res = append(res, groupChildren(OperationOr, orChain))
+			orChain = nil
+		}
+	}
+
+	for {
+		t := p.peek()
+		if t.typ == closing {
+			p.next()
+			break
+		}
+		if t.typ == tokenEOF {
+			return nil, fmt.Errorf("missing closing %s", closingName(closing))
+		}
+		if t.typ == tokenWord && (t.text == "OR" || t.text == "|") {
+			if len(orChain) == 0 {
+				return nil, errors.New("'OR' without a left operand")
+			}
+			p.next()
+			pendOr = true
+			continue
+		}
+		if t.typ == tokenWord && t.text == "AND" {
+			// The implicit operator is already an AND.
+			p.next()
+			continue
+		}
+
+		term, err := p.parseTerm(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !pendOr {
+			flushOr()
+		}
+		orChain = append(orChain, term)
+		pendOr = false
+	}
+
+	if pendOr {
+		return nil, errors.New("'OR' without a right operand")
+	}
+	flushOr()
+	return res, nil
+}
+
+func (p *queryParser) parseTerm(ctx queryContext) (CriteriaAST, error) {
+	t := p.next()
+
+	switch t.typ {
+	case tokenNot:
+		child, err := p.parseTerm(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{
+			Operation: OperationNot,
+			Children:  []CriteriaAST{child},
+		}, nil
+	case tokenOpenAnd, tokenOpenOr:
+		op, closing := OperationAnd, tokenCloseAnd
+		if t.typ == tokenOpenOr {
+			op, closing = OperationOr, tokenCloseOr
+		}
+		children, err := p.parseSequence(ctx, closing)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			return nil, errors.New("empty group")
+		}
+		return groupChildren(op, children), nil
+	case tokenQuoted:
+		return ctx.leaf(t.text, true), nil
+	case tokenWord:
+		return p.parseWord(ctx, t.text)
+	case tokenEOF:
+		return nil, errors.New("unexpected end of query")
+	default:
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+}
+
+func (p *queryParser) parseWord(ctx queryContext, word string) (CriteriaAST, error) {
+	if word == "AROUND" {
+		return nil, errors.New("unsupported operator AROUND")
+	}
+	name, value, found := strings.Cut(word, ":")
+	if !found || !isOperatorName(name) || ctx.fn != FunctionHas {
+		// This is a plain word, or a nested operator that we don't
+		// interpret any further.
+		return ctx.leaf(word, false), nil
+	}
+
+	opctx := operatorContext(strings.ToLower(name))
+	if value != "" {
+		return opctx.leaf(value, false), nil
+	}
+	// The value is in the next token: e.g. 'from:(a b)' or 'subject:"foo bar"'.
+	if next := p.peek(); !next.glued || next.typ == tokenEOF {
+		return nil, fmt.Errorf("missing value for operator %q", name)
+	}
+	return p.parseTerm(opctx)
+}
+
+func operatorContext(name string) queryContext {
+	switch name {
+	case "from":
+		return queryContext{fn: FunctionFrom}
+	case "to":
+		return queryContext{fn: FunctionTo}
+	case "cc":
+		return queryContext{fn: FunctionCc}
+	case "bcc":
+		return queryContext{fn: FunctionBcc}
+	case "replyto":
+		return queryContext{fn: FunctionReplyTo}
+	case "subject":
+		return queryContext{fn: FunctionSubject}
+	case "list":
+		return queryContext{fn: FunctionList}
+	default:
+		return queryContext{fn: FunctionQuery, operator: name}
+	}
+}
+
+func isOperatorName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func groupChildren(op OperationType, children []CriteriaAST) CriteriaAST {
+	if len(children) == 1 {
+		return children[0]
+	}
+	return &Node{
+		Operation: op,
+		Children:  children,
+	}
+}
+
+func closingName(t tokenType) string {
+	switch t {
+	case tokenCloseAnd:
+		return "')'"
+	case tokenCloseOr:
+		return "'}'"
+	default:
+		return "token"
+	}
+}
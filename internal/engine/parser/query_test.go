@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected CriteriaAST
+	}{
+		{
+			name:     "single word",
+			query:    "foo",
+			expected: fn1(FunctionHas, "foo"),
+		},
+		{
+			name:     "function",
+			query:    "from:a@b.com",
+			expected: fn1(FunctionFrom, "a@b.com"),
+		},
+		{
+			name:     "quoted",
+			query:    `subject:"foo bar"`,
+			expected: fn1(FunctionSubject, "foo bar"),
+		},
+		{
+			name:     "grouped args",
+			query:    "from:{a b} list:(c d)",
+			expected: and(fn(FunctionFrom, OperationOr, "a", "b"), fn(FunctionList, OperationAnd, "c", "d")),
+		},
+		{
+			name:     "negation",
+			query:    "-to:me foo",
+			expected: and(fn1(FunctionHas, "foo"), not(fn1(FunctionTo, "me"))),
+		},
+		{
+			name:     "or keyword",
+			query:    "a b OR c",
+			expected: and(fn(FunctionHas, OperationAnd, "a"), fn(FunctionHas, OperationOr, "b", "c")),
+		},
+		{
+			name:     "other operators",
+			query:    `filename:{pdf "my doc.txt"} has:attachment`,
+			expected: and(fn(FunctionQuery, OperationOr, "filename:pdf", `filename:"my doc.txt"`), fn(FunctionQuery, OperationNone, "has:attachment")),
+		},
+		{
+			name:     "negation in group",
+			query:    "(-from:a)",
+			expected: not(fn1(FunctionFrom, "a")),
+		},
+		{
+			name:     "negated first member of or",
+			query:    "{-from:a to:b}",
+			expected: or(not(fn1(FunctionFrom, "a")), fn1(FunctionTo, "b")),
+		},
+		{
+			name:     "negated group",
+			query:    "-(from:a to:b)",
+			expected: not(and(fn1(FunctionFrom, "a"), fn1(FunctionTo, "b"))),
+		},
+		{
+			name:     "dash inside word",
+			query:    "e-mail",
+			expected: fn1(FunctionHas, "e-mail"),
+		},
+		{
+			name:     "nested groups",
+			query:    "{from:a (to:b -cc:c)}",
+			expected: or(and(fn1(FunctionTo, "b"), not(fn1(FunctionCc, "c"))), fn1(FunctionFrom, "a")),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseQuery(tc.query)
+			assert.Nil(t, err)
+			expected, _ := SimplifyCriteria(tc.expected)
+			assert.Equal(t, expected, got)
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"(a b",
+		"a}",
+		"OR a",
+		"a OR",
+		"from:",
+		"dinner AROUND 5 friday",
+	}
+
+	for _, q := range tests {
+		t.Run(q, func(t *testing.T) {
+			_, err := ParseQuery(q)
+			assert.NotNil(t, err)
+		})
+	}
+}
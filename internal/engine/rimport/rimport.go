@@ -49,10 +49,18 @@ func fromLabel(l label.Label) v1alpha3.Label {
 			Text:       l.Color.Text,
 		}
 	}
-	return v1alpha3.Label{
+	res := v1alpha3.Label{
 		Name:  l.Name,
 		Color: color,
 	}
+	// Only the visibilities different from the default are imported.
+	if l.LabelList != label.Show {
+		res.LabelList = string(l.LabelList)
+	}
+	if l.MessageList != label.Show {
+		res.MessageList = string(l.MessageList)
+	}
+	return res
 }
 
 func fromFilter(f filter.Filter) (v1alpha3.Rule, error) {
@@ -61,6 +61,8 @@ func NewService(ctx context.Context, t *testing.T) *gmailv1.Service {
 		http.HandlerFunc(srv.HandleLabelsGet)).Methods(http.MethodGet)
 	mux.Handle("/gmail/v1/users/me/labels",
 		http.HandlerFunc(srv.HandleLabelsPost)).Methods(http.MethodPost)
+	mux.Handle("/gmail/v1/users/me/labels/{id}",
+		http.HandlerFunc(srv.HandleLabelGet)).Methods(http.MethodGet)
 	mux.Handle("/gmail/v1/users/me/labels/{id}",
 		http.HandlerFunc(srv.HandleLabelDelete)).Methods(http.MethodDelete)
 	mux.Handle("/gmail/v1/users/me/labels/{id}",
@@ -110,6 +112,16 @@ func (g *gmailServer) HandleLabelsPost(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, res)
 }
 
+func (g *gmailServer) HandleLabelGet(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	res, err := g.Label(mux.Vars(r)["id"])
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	writeResponse(w, res)
+}
+
 func (g *gmailServer) HandleLabelDelete(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	id := mux.Vars(r)["id"]
@@ -220,6 +232,19 @@ func (g *gmail) Labels() []*gmailv1.Label {
 	return res
 }
 
+// Label returns the label with the given ID. There are no messages, so the
+// label is always empty.
+func (g *gmail) Label(id string) (*gmailv1.Label, error) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	l, ok := g.labels[id]
+	if !ok || l == nil {
+		return nil, statusError{http.StatusNotFound, fmt.Errorf("id %q not found", id)}
+	}
+	return l, nil
+}
+
 func (g *gmail) CreateLabel(l *gmailv1.Label) (*gmailv1.Label, error) {
 	g.m.Lock()
 	defer g.m.Unlock()
@@ -264,6 +289,12 @@ func (g *gmail) UpdateLabel(l *gmailv1.Label) (*gmailv1.Label, error) {
 		// Only update the color if it was passed in.
 		target.Color = l.Color
 	}
+	if l.LabelListVisibility != "" {
+		target.LabelListVisibility = l.LabelListVisibility
+	}
+	if l.MessageListVisibility != "" {
+		target.MessageListVisibility = l.MessageListVisibility
+	}
 	if target.Name != l.Name {
 		delete(g.labelNames, target.Name)
 		g.labelNames.Add(l.Name)
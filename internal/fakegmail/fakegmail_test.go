@@ -18,7 +18,7 @@ func TestLabels(t *testing.T) {
 	api := api.NewFromService(svc)
 
 	// Add.
-	err := api.AddLabels(label.Labels{
+	ids, err := api.AddLabels(label.Labels{
 		{
 			Name:  "Label1",
 			Color: &label.Color{Background: "red", Text: "blue"},
@@ -31,13 +31,24 @@ func TestLabels(t *testing.T) {
 	ls, err := api.ListLabels()
 	assert.Nil(t, err)
 	assert.Len(t, ls, 2)
+	assert.Equal(t, []string{ls[0].ID, ls[1].ID}, ids)
 
-	// Add duplicate.
-	err = api.AddLabels(label.Labels{{Name: "Label2"}})
+	// Add duplicate, after a new one.
+	ids, err = api.AddLabels(label.Labels{{Name: "Label3"}, {Name: "Label2"}})
+	assert.NotNil(t, err)
+	assert.Len(t, ids, 1)
+	_, err = api.DeleteLabels(ids)
+	assert.Nil(t, err)
+
+	// Usage.
+	u, err := api.LabelUsage(ls[0].ID)
+	assert.Nil(t, err)
+	assert.True(t, u.Empty())
+	_, err = api.LabelUsage("missing")
 	assert.NotNil(t, err)
 
 	// Delete.
-	err = api.DeleteLabels([]string{ls[0].ID})
+	_, err = api.DeleteLabels([]string{ls[0].ID})
 	assert.Nil(t, err)
 	ls, err = api.ListLabels()
 	assert.Nil(t, err)
@@ -48,7 +59,7 @@ func TestLabels(t *testing.T) {
 		Background: "green",
 		Text:       "blue",
 	}
-	err = api.UpdateLabels(ls)
+	_, err = api.UpdateLabels(ls)
 	assert.Nil(t, err)
 	ls, err = api.ListLabels()
 	assert.Nil(t, err)
@@ -62,11 +73,11 @@ func TestFilters(t *testing.T) {
 	api := api.NewFromService(svc)
 
 	// Add label.
-	err := api.AddLabels(label.Labels{{Name: "label1"}})
+	_, err := api.AddLabels(label.Labels{{Name: "label1"}})
 	assert.Nil(t, err)
 
 	// Add.
-	err = api.AddFilters(filter.Filters{
+	ids, err := api.AddFilters(filter.Filters{
 		{
 			Criteria: filter.Criteria{
 				From: "address@mail.com",
@@ -91,9 +102,10 @@ func TestFilters(t *testing.T) {
 	fs, err := api.ListFilters()
 	assert.Nil(t, err)
 	assert.Len(t, fs, 2)
+	assert.ElementsMatch(t, []string{fs[0].ID, fs[1].ID}, ids)
 
 	// Add duplicate.
-	err = api.AddFilters(filter.Filters{
+	_, err = api.AddFilters(filter.Filters{
 		{
 			Criteria: filter.Criteria{
 				Subject: "foo",
@@ -106,7 +118,7 @@ func TestFilters(t *testing.T) {
 	assert.NotNil(t, err)
 
 	// Add with non existing label.
-	err = api.AddFilters(filter.Filters{
+	_, err = api.AddFilters(filter.Filters{
 		{
 			Criteria: filter.Criteria{
 				Subject: "bar",
@@ -119,7 +131,7 @@ func TestFilters(t *testing.T) {
 	assert.NotNil(t, err)
 
 	// Delete.
-	err = api.DeleteFilters([]string{fs[0].ID})
+	_, err = api.DeleteFilters([]string{fs[0].ID})
 	assert.Nil(t, err)
 	fs, err = api.ListFilters()
 	assert.Nil(t, err)
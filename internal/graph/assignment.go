@@ -0,0 +1,117 @@
+package graph
+
+import "math"
+
+// MinCostAssignment solves the assignment problem for the given [nrow][ncol]
+// cost matrix, minimising the total cost.
+//
+// The result has the same format as Munkres.Links: j := res[i] means that i is
+// assigned to j, and -1 means no assignment. Costs must be finite.
+//
+// This is the shortest augmenting path variant of the Hungarian algorithm,
+// using potentials. It runs in O(n²m), where n <= m are the sizes of the
+// matrix, but unlike Munkres it doesn't rescan the whole matrix at every step,
+// which makes it much faster in practice.
+func MinCostAssignment(c [][]float64) []int {
+	n := len(c)
+	if n == 0 {
+		return nil
+	}
+	m := len(c[0])
+	if n > m {
+		// The algorithm requires at most as many rows as columns.
+		colLinks := MinCostAssignment(transpose(c))
+		res := make([]int, n)
+		for i := range res {
+			res[i] = -1
+		}
+		for j, i := range colLinks {
+			if i >= 0 {
+				res[i] = j
+			}
+		}
+		return res
+	}
+	for _, row := range c {
+		for _, x := range row {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				Panic("cannot solve assignment because of non finite cost")
+			}
+		}
+	}
+
+	// Rows and columns are 1-based here, to use the column 0 as a sentinel.
+	u := make([]float64, n+1)    // row potentials
+	v := make([]float64, m+1)    // column potentials
+	p := make([]int, m+1)        // p[j] is the row assigned to column j, 0 if none
+	way := make([]int, m+1)      // previous column in the augmenting path
+	minv := make([]float64, m+1) // minimum reduced cost reaching each column
+	used := make([]bool, m+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = math.Inf(1)
+			used[j] = false
+		}
+		// Grow the shortest path tree from row i, until it reaches a free
+		// column.
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := c[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		// Augment along the path.
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	res := make([]int, n)
+	for i := range res {
+		res[i] = -1
+	}
+	for j := 1; j <= m; j++ {
+		if p[j] != 0 {
+			res[p[j]-1] = j - 1
+		}
+	}
+	return res
+}
+
+func transpose(c [][]float64) [][]float64 {
+	res := Alloc(len(c[0]), len(c))
+	for i, row := range c {
+		for j, x := range row {
+			res[j][i] = x
+		}
+	}
+	return res
+}
@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomMatrix(r *rand.Rand, nrow, ncol int) [][]float64 {
+	c := Alloc(nrow, ncol)
+	for i := range c {
+		for j := range c[i] {
+			c[i][j] = r.Float64()
+		}
+	}
+	return c
+}
+
+func munkresLinks(c [][]float64) []int {
+	var mnk Munkres
+	mnk.Init(len(c), len(c[0]))
+	mnk.SetCostMatrix(c)
+	mnk.Run()
+	return mnk.Links
+}
+
+func linksCost(c [][]float64, links []int) float64 {
+	res := 0.0
+	for i, j := range links {
+		if j >= 0 {
+			res += c[i][j]
+		}
+	}
+	return res
+}
+
+func TestMinCostAssignment(t *testing.T) {
+	c := [][]float64{
+		{2, 3, 3},
+		{3, 2, 3},
+		{3, 3, 2},
+	}
+	assert.Equal(t, []int{0, 1, 2}, MinCostAssignment(c))
+
+	c = [][]float64{
+		{1, 2, 3},
+		{2, 4, 6},
+		{3, 6, 9},
+	}
+	assert.Equal(t, []int{2, 1, 0}, MinCostAssignment(c))
+}
+
+func TestMinCostAssignmentRectangular(t *testing.T) {
+	// More columns than rows.
+	c := [][]float64{
+		{5, 1, 5},
+		{1, 5, 5},
+	}
+	assert.Equal(t, []int{1, 0}, MinCostAssignment(c))
+
+	// More rows than columns.
+	c = [][]float64{
+		{5, 1},
+		{5, 5},
+		{1, 5},
+	}
+	assert.Equal(t, []int{1, -1, 0}, MinCostAssignment(c))
+}
+
+func TestMinCostAssignmentEmpty(t *testing.T) {
+	assert.Nil(t, MinCostAssignment(nil))
+}
+
+func TestMinCostAssignmentNaN(t *testing.T) {
+	c := [][]float64{{1, 2}, {3, 0}}
+	c[1][1] = c[1][1] / c[1][1]
+	assert.Panics(t, func() { MinCostAssignment(c) })
+}
+
+func TestMinCostAssignmentMatchesMunkres(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	sizes := [][2]int{{2, 2}, {5, 5}, {7, 3}, {3, 7}, {20, 20}, {30, 25}}
+
+	for _, s := range sizes {
+		for k := 0; k < 10; k++ {
+			c := randomMatrix(r, s[0], s[1])
+			links := MinCostAssignment(c)
+			// The assignment is not necessarily the same, but the cost
+			// has to be the optimal one.
+			assert.InDelta(t, linksCost(c, munkresLinks(c)), linksCost(c, links), 1e-9,
+				"size %v", s)
+			assigned := 0
+			seen := map[int]bool{}
+			for _, j := range links {
+				if j < 0 {
+					continue
+				}
+				assert.False(t, seen[j], "column %d assigned twice", j)
+				seen[j] = true
+				assigned++
+			}
+			assert.Equal(t, min(s[0], s[1]), assigned)
+		}
+	}
+}
+
+var benchSizes = []int{50, 100, 200}
+
+func BenchmarkMunkres(b *testing.B) {
+	for _, n := range benchSizes {
+		c := randomMatrix(rand.New(rand.NewSource(1)), n, n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				munkresLinks(c)
+			}
+		})
+	}
+}
+
+func BenchmarkMinCostAssignment(b *testing.B) {
+	for _, n := range benchSizes {
+		c := randomMatrix(rand.New(rand.NewSource(1)), n, n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				MinCostAssignment(c)
+			}
+		})
+	}
+}
@@ -2,10 +2,21 @@ package reporting
 
 import (
 	"strings"
+	"unicode"
 
 	"github.com/fatih/color"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
+// Minimum similarity between a removed and an added line, for the changed
+// words in them to be highlighted. Below this, lines are too different for
+// the highlighting to be useful.
+const minWordDiffSimilarity = 0.4
+
+// ColorizeDiff colors a unified diff for the terminal.
+//
+// Removed lines immediately followed by added ones are paired, and the words
+// that changed between the two lines of each pair are highlighted.
 func ColorizeDiff(diff string) string {
 	coloredDiff := &strings.Builder{}
 	lines := strings.Split(diff, "\n")
@@ -18,11 +29,27 @@ func ColorizeDiff(diff string) string {
 	colorGreen := color.New(color.FgGreen)
 	colorGreen.EnableColor()
 
+	// Highlighted words in paired lines.
+	highlightedRed := color.New(color.FgRed, color.ReverseVideo)
+	highlightedRed.EnableColor()
+	highlightedGreen := color.New(color.FgGreen, color.ReverseVideo)
+	highlightedGreen.EnableColor()
+
+	pairs := pairChangedLines(lines)
+
 	for i, line := range lines {
 		if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++") {
 			colorBold.Fprint(coloredDiff, line)
 		} else if strings.HasPrefix(line, "@@") {
 			colorCyan.Fprint(coloredDiff, line)
+		} else if j, ok := pairs[i]; ok && j > i {
+			removed, _ := wordDiff(line[1:], lines[j][1:])
+			colorRed.Fprint(coloredDiff, "-")
+			writeWords(coloredDiff, removed, colorRed, highlightedRed)
+		} else if j, ok := pairs[i]; ok {
+			_, added := wordDiff(lines[j][1:], line[1:])
+			colorGreen.Fprint(coloredDiff, "+")
+			writeWords(coloredDiff, added, colorGreen, highlightedGreen)
 		} else if strings.HasPrefix(line, "-") {
 			colorRed.Fprint(coloredDiff, line)
 		} else if strings.HasPrefix(line, "+") {
@@ -36,3 +63,131 @@ func ColorizeDiff(diff string) string {
 	}
 	return coloredDiff.String()
 }
+
+// pairChangedLines finds blocks of removed lines immediately followed by
+// blocks of added lines, and pairs them in order. Pairs of lines too
+// different from each other are ignored.
+//
+// The result maps the index of each paired line to the index of the other
+// line of the pair.
+func pairChangedLines(lines []string) map[int]int {
+	res := map[int]int{}
+	isRemoved := func(l string) bool {
+		return strings.HasPrefix(l, "-") && !strings.HasPrefix(l, "---")
+	}
+	isAdded := func(l string) bool {
+		return strings.HasPrefix(l, "+") && !strings.HasPrefix(l, "+++")
+	}
+
+	for i := 0; i < len(lines); {
+		if !isRemoved(lines[i]) {
+			i++
+			continue
+		}
+		remStart := i
+		for i < len(lines) && isRemoved(lines[i]) {
+			i++
+		}
+		addStart := i
+		for i < len(lines) && isAdded(lines[i]) {
+			i++
+		}
+		for k := 0; k < addStart-remStart && addStart+k < i; k++ {
+			r, a := remStart+k, addStart+k
+			if wordSimilarity(lines[r][1:], lines[a][1:]) < minWordDiffSimilarity {
+				continue
+			}
+			res[r] = a
+			res[a] = r
+		}
+	}
+
+	return res
+}
+
+// word is a part of a line, which can be highlighted as changed.
+type word struct {
+	text    string
+	changed bool
+}
+
+// wordDiff compares the words of the two lines, and returns them marking the
+// ones that are only in one of the two.
+func wordDiff(a, b string) (removed, added []word) {
+	wa, wb := splitWords(a), splitWords(b)
+	m := difflib.NewMatcher(wa, wb)
+	for _, op := range m.GetOpCodes() {
+		equal := op.Tag == 'e'
+		for _, w := range wa[op.I1:op.I2] {
+			removed = append(removed, word{w, !equal})
+		}
+		for _, w := range wb[op.J1:op.J2] {
+			added = append(added, word{w, !equal})
+		}
+	}
+	return removed, added
+}
+
+func wordSimilarity(a, b string) float64 {
+	return difflib.NewMatcher(splitWords(a), splitWords(b)).Ratio()
+}
+
+// splitWords splits the line into words: runs of letters and digits, runs of
+// spaces and single punctuation characters. Concatenating the words returns
+// the original line.
+func splitWords(s string) []string {
+	var res []string
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		j := i + 1
+		switch {
+		case isWordRune(rs[i]):
+			for j < len(rs) && isWordRune(rs[j]) {
+				j++
+			}
+		case unicode.IsSpace(rs[i]):
+			for j < len(rs) && unicode.IsSpace(rs[j]) {
+				j++
+			}
+		}
+		res = append(res, string(rs[i:j]))
+		i = j
+	}
+	return res
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func writeWords(w *strings.Builder, words []word, normal, highlighted *color.Color) {
+	// Merge adjacent words with the same highlighting, to reduce the number
+	// of escape sequences.
+	for i := 0; i < len(words); {
+		j := i
+		var text strings.Builder
+		for j < len(words) && words[j].changed == words[i].changed {
+			text.WriteString(words[j].text)
+			j++
+		}
+		if !words[i].changed {
+			normal.Fprint(w, text.String())
+			i = j
+			continue
+		}
+		// Surrounding spaces are not highlighted.
+		t := text.String()
+		trimmed := strings.TrimLeftFunc(t, unicode.IsSpace)
+		if lead := t[:len(t)-len(trimmed)]; lead != "" {
+			normal.Fprint(w, lead)
+		}
+		core := strings.TrimRightFunc(trimmed, unicode.IsSpace)
+		if core != "" {
+			highlighted.Fprint(w, core)
+		}
+		if trail := trimmed[len(core):]; trail != "" {
+			normal.Fprint(w, trail)
+		}
+		i = j
+	}
+}
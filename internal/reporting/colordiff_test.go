@@ -0,0 +1,68 @@
+package reporting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	bold       = "\x1b[1m"
+	cyan       = "\x1b[36m"
+	red        = "\x1b[31m"
+	green      = "\x1b[32m"
+	redHigh    = "\x1b[31;7m"
+	greenHigh  = "\x1b[32;7m"
+	resetColor = "\x1b[0m"
+)
+
+func TestColorizeDiff(t *testing.T) {
+	diff := "--- a\n" +
+		"+++ b\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" context\n" +
+		"-from: foo@bar.com\n" +
+		"+from: foo@baz.com\n"
+
+	expected := bold + "--- a" + resetColor + "\n" +
+		bold + "+++ b" + resetColor + "\n" +
+		cyan + "@@ -1,2 +1,2 @@" + resetColor + "\n" +
+		" context\n" +
+		red + "-" + resetColor + red + "from: foo@" + resetColor +
+		redHigh + "bar" + resetColor + red + ".com" + resetColor + "\n" +
+		green + "+" + resetColor + green + "from: foo@" + resetColor +
+		greenHigh + "baz" + resetColor + green + ".com" + resetColor + "\n"
+	assert.Equal(t, expected, ColorizeDiff(diff))
+}
+
+func TestColorizeDiffUnpaired(t *testing.T) {
+	// Lines that are too different, or without a counterpart, are colored
+	// as a whole.
+	diff := "-completely different\n" +
+		"-removed\n" +
+		"+nothing in common here\n"
+
+	expected := red + "-completely different" + resetColor + "\n" +
+		red + "-removed" + resetColor + "\n" +
+		green + "+nothing in common here" + resetColor + "\n"
+	assert.Equal(t, expected, ColorizeDiff(diff))
+}
+
+func TestSplitWords(t *testing.T) {
+	assert.Equal(t,
+		[]string{"  ", "from", ":", " ", "{", "a", "@", "b", ".", "com", "}"},
+		splitWords("  from: {a@b.com}"))
+	assert.Nil(t, splitWords(""))
+}
+
+func TestPairChangedLines(t *testing.T) {
+	lines := []string{
+		" same",
+		"-label: foo",
+		"-label: bar",
+		"+label: baz",
+		" same",
+		"+label: qux",
+	}
+	assert.Equal(t, map[int]int{1: 3, 3: 1}, pairChangedLines(lines))
+}